@@ -4,26 +4,399 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/simon-wg/wfinfo-go/internal"
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
 )
 
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] replay [replay options] <EE.log>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] list-windows\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] relics\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] inventory [-record]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] ducats [ducats options] [parts.csv]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] history\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] export [export options] <%s>\n", os.Args[0], strings.Join(internal.ExportDatasets(), "|"))
+		fmt.Fprintf(os.Stderr, "       %s [options] owned [list | add <name> [count] | remove <name> [count] | set <name> <count> | import <parts.csv>]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nOptions:\n")
 		flag.PrintDefaults()
 	}
 
 	filePath := flag.String("f", "", "Path to EE.log (overrides -d)")
 	steamLibrary := flag.String("d", "~/.local/share/Steam", "Path to Steam library folder")
+	captureBackend := flag.String("capture", "x11", "Screen capture backend ("+strings.Join(internal.CaptureBackends(), ", ")+")")
+	captureSource := flag.String("capture-source", "", "Image file or directory read by the file capture backend")
+	captureRegion := flag.String("capture-region", "", "Game area of full screen captures as WIDTHxHEIGHT+X+Y, located from the screen when empty (portal backend)")
+	captureOutput := flag.String("capture-output", "", "Capture the game from this monitor, e.g. DP-1 (x11 backend)")
+	windowClass := flag.String("window-class", "", "Match the game window by WM_CLASS substring (default \"steam_app_230410\")")
+	windowTitle := flag.String("window-title", "", "Match the game window by title substring")
+	windowPID := flag.Uint("window-pid", 0, "Match the game window by process id")
+	windowID := flag.String("window-id", "", "Capture the window with this id, e.g. 0x3a00007")
+	themeName := flag.String("theme", "", "UI theme to use instead of identifying it ("+strings.Join(internal.Themes(), ", ")+")")
+	themeColor := flag.String("theme-color", "", "Custom theme text color as #RRGGBB")
+	themeThreshold := flag.Float64("theme-threshold", 0, "Color distance still counted as text (default 60 or the theme's)")
+	binarization := flag.String("binarize", "rgb", "How reward text is isolated before OCR (rgb, lab, otsu)")
+	pipeline := flag.String("preprocess", "", "Comma separated cleanup steps run before OCR: upscale[:N], denoise, dilate, erode, deskew")
+	gameLang := flag.String("lang", "en", "Language of the game client, item names are read in it")
+	displayLang := flag.String("display-lang", "", "Language results are printed in (default the game's)")
+	ocrEngine := flag.String("ocr", internal.DefaultOCREngine(), "OCR engine reading reward boxes ("+strings.Join(internal.OCREngines(), ", ")+"), glyph is experimental")
+	ocrWorkers := flag.Int("ocr-workers", 0, "Number of reward boxes read in parallel (default one per box, up to the CPU count)")
+	icons := flag.Bool("icons", false, "Compare the reward card artwork with item icons when the text matches several items")
+	ownedParts := flag.String("owned", "", "File of the prime parts owned, updated by inventory -record (default in the user data directory)")
+	historyFile := flag.String("history", "", "File every reward screen of a live run is added to, replays add none (default in the user data directory)")
+	recordPicks := flag.Bool("record-picks", false, "Ask for the reward picked after every reward screen and add it to the owned parts")
+	debugDir := flag.String("debug-dir", "", "Dump the images, OCR text and match candidates of every detection to this directory")
 	flag.Parse()
 
-	if err := internal.Run(*filePath, *steamLibrary); err != nil {
+	detect := []internal.DetectOption{}
+	if theme, ok, err := parseTheme(*themeName, *themeColor, *themeThreshold); err != nil {
+		usageError(err)
+	} else if ok {
+		detect = append(detect, internal.WithTheme(theme))
+	}
+	if method, err := internal.ParseBinarization(*binarization); err != nil {
+		usageError(err)
+	} else {
+		detect = append(detect, internal.WithBinarization(method))
+	}
+	if steps, err := internal.ParsePipeline(*pipeline); err != nil {
+		usageError(err)
+	} else if len(steps) > 0 {
+		detect = append(detect, internal.WithPreprocessing(steps...))
+	}
+	if *icons {
+		matcher, err := internal.NewIconMatcher()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+			os.Exit(1)
+		}
+		detect = append(detect, internal.WithIconMatcher(matcher))
+	}
+	if *debugDir != "" {
+		detect = append(detect, internal.WithDebugDir(*debugDir))
+	}
+
+	game, err := wfm.ParseLanguage(*gameLang)
+	if err != nil {
+		usageError(err)
+	}
+	var display wfm.Language
+	if *displayLang != "" {
+		if display, err = wfm.ParseLanguage(*displayLang); err != nil {
+			usageError(err)
+		}
+	}
+
+	if flag.Arg(0) == "replay" {
+		runReplay(flag.Args()[1:], internal.ReplayConfig{
+			OCREngine:       *ocrEngine,
+			OCRWorkers:      *ocrWorkers,
+			GameLanguage:    game,
+			DisplayLanguage: display,
+			Detect:          detect,
+			OwnedPartsPath:  *ownedParts,
+		})
+		return
+	}
+
+	if flag.Arg(0) == "ducats" {
+		runDucats(flag.Args()[1:], internal.DucatConfig{
+			OwnedPartsPath:  *ownedParts,
+			GameLanguage:    game,
+			DisplayLanguage: display,
+		})
+		return
+	}
+
+	if flag.Arg(0) == "history" {
+		if err := internal.RunHistory(internal.HistoryConfig{Path: *historyFile}); err != nil {
+			fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "export" {
+		runExport(flag.Args()[1:], internal.ExportConfig{
+			HistoryPath:    *historyFile,
+			OwnedPartsPath: *ownedParts,
+			GameLanguage:   game,
+		})
+		return
+	}
+
+	if flag.Arg(0) == "owned" {
+		runOwned(flag.Args()[1:], internal.OwnedConfig{
+			Path:            *ownedParts,
+			GameLanguage:    game,
+			DisplayLanguage: display,
+		})
+		return
+	}
+
+	cfg := internal.Config{
+		FilePath:        *filePath,
+		SteamLibrary:    *steamLibrary,
+		OCREngine:       *ocrEngine,
+		OCRWorkers:      *ocrWorkers,
+		GameLanguage:    game,
+		DisplayLanguage: display,
+		Detect:          detect,
+		OwnedPartsPath:  *ownedParts,
+		RecordPicks:     *recordPicks,
+		HistoryPath:     *historyFile,
+		Capture: internal.CaptureConfig{
+			Backend: *captureBackend,
+			Source:  *captureSource,
+			Output:  *captureOutput,
+			Window: internal.WindowMatch{
+				Class: *windowClass,
+				Title: *windowTitle,
+				PID:   uint32(*windowPID),
+			},
+		},
+	}
+	if *captureRegion != "" {
+		region, err := internal.ParseGeometry(*captureRegion)
+		if err != nil {
+			usageError(err)
+		}
+		cfg.Capture.Region = region
+	}
+	if *windowID != "" {
+		id, err := strconv.ParseUint(*windowID, 0, 32)
+		if err != nil {
+			usageError(fmt.Errorf("invalid window id %q", *windowID))
+		}
+		cfg.Capture.Window.ID = uint32(id)
+	}
+
+	if flag.Arg(0) == "list-windows" {
+		if err := internal.ListWindows(os.Stdout, cfg.Capture.Window); err != nil {
+			fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "relics" {
+		if err := internal.RunRelics(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "inventory" {
+		runInventory(flag.Args()[1:], cfg)
+		return
+	}
+
+	if err := internal.Run(cfg); err != nil {
 		handleError(err, *filePath, *steamLibrary)
 	}
 }
 
+// runReplay parses the replay options into cfg, which holds the global ones,
+// and replays.
+func runReplay(args []string, cfg internal.ReplayConfig) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s replay [replay options] <EE.log>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nRewards are printed as in a live run, but not added to the history.\n")
+		fmt.Fprintf(os.Stderr, "\nReplay options:\n")
+		fs.PrintDefaults()
+	}
+	screenshotDir := fs.String("s", "", "Directory of screenshots used in place of screen captures, in name order")
+	speed := fs.Float64("speed", 1, "Replay speed multiplier (0 replays without delay)")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 || *screenshotDir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg.LogPath = fs.Arg(0)
+	cfg.ScreenshotDir = *screenshotDir
+	cfg.Speed = *speed
+	if err := internal.Replay(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runInventory parses the inventory options into cfg, which holds the global
+// ones, and reads the inventory.
+func runInventory(args []string, cfg internal.Config) {
+	fs := flag.NewFlagSet("inventory", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s inventory [inventory options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nInventory options:\n")
+		fs.PrintDefaults()
+	}
+	record := fs.Bool("record", false, "Record the counts read in the owned parts file, check them in the table first")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg.RecordInventory = *record
+	if err := internal.RunInventory(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runDucats parses the ducats options into cfg, which holds the global ones,
+// and plans the trades.
+func runDucats(args []string, cfg internal.DucatConfig) {
+	fs := flag.NewFlagSet("ducats", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s ducats [ducats options] [parts.csv]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nReads rows of part name and count, or the owned parts without a file, and recommends trading each part for ducats or selling it.\n")
+		fmt.Fprintf(os.Stderr, "\nDucats options:\n")
+		fs.PrintDefaults()
+	}
+	ratio := fs.Float64("ratio", internal.DefaultDucatRatio, "Ducats per platinum from which parts are traded for ducats")
+	sellAbove := fs.Float64("sell-above", 0, "Price from which parts are always sold, whatever their ducats")
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 || *ratio <= 0 || *sellAbove < 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg.PartsPath = fs.Arg(0)
+	cfg.MinRatio = *ratio
+	cfg.SellAbove = *sellAbove
+	if err := internal.RunDucats(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runExport parses the export options into cfg, which holds the global ones,
+// and exports.
+func runExport(args []string, cfg internal.ExportConfig) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s export [export options] <%s>\n", os.Args[0], strings.Join(internal.ExportDatasets(), "|"))
+		fmt.Fprintf(os.Stderr, "\nExport options:\n")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "csv", "Output format (csv, json)")
+	output := fs.String("o", "", "File written instead of standard output")
+	from := fs.String("from", "", "Keep history and prices from this date, YYYY-MM-DD or an RFC 3339 time")
+	to := fs.String("to", "", "Keep history and prices up to this date, YYYY-MM-DD (included) or an RFC 3339 time")
+	fs.Func("item", "Keep rows of items whose name or id contains this text, may be repeated", func(item string) error {
+		cfg.Items = append(cfg.Items, item)
+		return nil
+	})
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	start, end, err := internal.ParseDateRange(*from, *to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg.Dataset = fs.Arg(0)
+	cfg.Format = *format
+	cfg.OutputPath = *output
+	cfg.From, cfg.To = start, end
+	if err := internal.RunExport(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runOwned lists or edits the owned parts as args, the owned command's
+// arguments, ask.
+func runOwned(args []string, cfg internal.OwnedConfig) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s owned [list]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s owned add <name> [count]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s owned remove <name> [count]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s owned set <name> <count>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s owned import <parts.csv>\n", os.Args[0])
+		os.Exit(2)
+	}
+	// count parses the count argument of add, remove and set, def when absent
+	count := func(def int) int {
+		if len(args) == 2 {
+			return def
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "Invalid count %q\n", args[2])
+			usage()
+		}
+		return n
+	}
+
+	var err error
+	switch {
+	case len(args) == 0 || len(args) == 1 && args[0] == "list":
+		err = internal.ListOwned(cfg)
+	case len(args) == 2 && args[0] == "import":
+		err = internal.ImportOwned(cfg, args[1])
+	case len(args) < 2 || len(args) > 3:
+		usage()
+	case args[0] == "add":
+		err = internal.EditOwned(cfg, args[1], count(1), false)
+	case args[0] == "remove":
+		err = internal.EditOwned(cfg, args[1], -count(1), false)
+	case args[0] == "set" && len(args) == 3:
+		err = internal.EditOwned(cfg, args[1], count(0), true)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseTheme builds the theme selected by the theme flags, ok is false when
+// the theme should be identified from the screen.
+func parseTheme(name, hex string, threshold float64) (theme internal.Theme, ok bool, err error) {
+	switch {
+	case name != "" && hex != "":
+		return theme, false, fmt.Errorf("-theme and -theme-color can't be combined")
+	case hex != "":
+		theme, err = internal.CustomTheme(hex, threshold)
+		return theme, err == nil, err
+	case name != "":
+		theme, err = internal.LookupTheme(name)
+		if err != nil {
+			return theme, false, err
+		}
+		if threshold > 0 {
+			theme.Threshold = threshold
+		}
+		return theme, true, nil
+	case threshold != 0:
+		return theme, false, fmt.Errorf("-theme-threshold needs -theme or -theme-color")
+	}
+	return theme, false, nil
+}
+
+func usageError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+	flag.Usage()
+	os.Exit(2)
+}
+
 func handleError(err error, filePath, steamLibrary string) {
 	if os.IsNotExist(err) {
 		fmt.Fprintf(os.Stderr, "Error: Invalid path to EE.log or Steam library.\n")
@@ -5,11 +5,10 @@ go 1.26
 require (
 	github.com/anthonynsimon/bild v0.14.0
 	github.com/fsnotify/fsnotify v1.9.0
+	github.com/godbus/dbus/v5 v5.2.1
 	github.com/jezek/xgb v1.3.0
 	github.com/otiai10/gosseract/v2 v2.4.1
+	golang.org/x/sys v0.41.0
 )
 
-require (
-	golang.org/x/image v0.36.0 // indirect
-	golang.org/x/sys v0.41.0 // indirect
-)
+require golang.org/x/image v0.36.0 // indirect
@@ -3,6 +3,7 @@ package internal
 import (
 	"bufio"
 	"fmt"
+	"image"
 	"io"
 	"log"
 	"os"
@@ -12,16 +13,63 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/otiai10/gosseract/v2"
 	"github.com/simon-wg/wfinfo-go/internal/wfm"
 )
 
-func Run(filePath string, steamLibrary string) error {
-	fullPath, err := resolveEEPath(filePath, steamLibrary)
+// Config holds the options for Run.
+type Config struct {
+	// FilePath is the path to EE.log, takes precedence over SteamLibrary.
+	FilePath     string
+	SteamLibrary string
+	Capture      CaptureConfig
+	// OCREngine is the name of the engine reading reward boxes, defaults to
+	// DefaultOCREngine.
+	OCREngine string
+	// GameLanguage is the language of the game client, reward text is read
+	// and matched in it. Defaults to English.
+	GameLanguage wfm.Language
+	// DisplayLanguage is the language results are printed in, defaults to
+	// GameLanguage.
+	DisplayLanguage wfm.Language
+	// OCRWorkers is the number of reward box lines the engine reads in
+	// parallel, 0 picks a default.
+	OCRWorkers int
+	// Detect configures every detection.
+	Detect []DetectOption
+	// OwnedPartsPath is the file of the parts owned, which the inventory
+	// command updates with RecordInventory. Defaults to a file in the user
+	// data directory.
+	OwnedPartsPath string
+	// RecordInventory has the inventory command record the counts it reads
+	// in the owned parts file. Counts are read by OCR and a misread one would
+	// replace the right count, so they're only printed unless asked for.
+	RecordInventory bool
+	// RecordPicks asks for the reward picked after every reward screen and
+	// adds it to the owned parts, as EE.log doesn't tell which was picked.
+	RecordPicks bool
+	// HistoryPath is the file every reward screen is added to, defaults to
+	// a file in the user data directory.
+	HistoryPath string
+}
+
+func Run(cfg Config) error {
+	fullPath, err := resolveEEPath(cfg.FilePath, cfg.SteamLibrary)
 	if err != nil {
 		return err
 	}
 
+	capturer, err := NewCapturer(cfg.Capture)
+	if err != nil {
+		return err
+	}
+	if closer, ok := capturer.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				log.Printf("Error closing capturer: %v", err)
+			}
+		}()
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
@@ -48,9 +96,9 @@ func Run(filePath string, steamLibrary string) error {
 		return err
 	}
 
-	ocrClient := gosseract.NewClient()
-	if err := ocrClient.SetWhitelist("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ& \n"); err != nil {
-		return fmt.Errorf("failed to configure OCR: %w", err)
+	ocr, err := NewOCREngine(cfg.OCREngine, cfg.OCRWorkers, cfg.GameLanguage)
+	if err != nil {
+		return err
 	}
 
 	s := &appState{
@@ -59,32 +107,78 @@ func Run(filePath string, steamLibrary string) error {
 		},
 		detection:  &detectionState{},
 		foundItems: make(chan []wfm.Item),
-		ocrClient:  ocrClient,
+		ocr:        ocr,
+		capturer:   capturer,
+		detectOpts: append([]DetectOption{WithLanguage(gameLanguage(cfg.GameLanguage))}, cfg.Detect...),
 	}
 	defer func() {
-		if err := ocrClient.Close(); err != nil {
-			log.Printf("Error closing OCR client: %v", err)
+		if err := ocr.Close(); err != nil {
+			log.Printf("Error closing OCR engine: %v", err)
 		}
 	}()
 
 	log.Printf("Watching %s for relic screen\n", fullPath)
 
+	ownedPath, err := ownedPartsPath(cfg.OwnedPartsPath)
+	if err != nil {
+		return err
+	}
+	history, err := historyPath(cfg.HistoryPath)
+	if err != nil {
+		return err
+	}
+	session := time.Now()
+	drops, err := relicDropTable()
+	if err != nil {
+		log.Printf("Relics won't be recorded in the history: %v", err)
+	}
 	wfmClient := wfm.NewClient()
+	sets := newSetTracker(wfm.NewClient(wfm.WithLanguage(gameLanguage(cfg.GameLanguage))), ownedPath)
+	names := displayNames(gameLanguage(cfg.GameLanguage), cfg.DisplayLanguage)
+
+	// picks stays nil, never ready, unless picks are recorded
+	var picks chan string
+	var rewards []wfm.Item
+	var shown time.Time
+	if cfg.RecordPicks {
+		picks = make(chan string)
+		go readLines(os.Stdin, picks)
+	}
 
 	for {
 		select {
 		case items := <-s.foundItems:
-			for _, item := range items {
-				detailedInfo, err := wfmClient.FetchItemTopOrders(item.Id, nil)
-				if err != nil {
-					log.Printf("Error: Unable to fetch price information for %v, %v\n", item.Id, err)
-				}
-				var sumPrice float32
-				for _, order := range detailedInfo.Sell {
-					sumPrice += float32(order.Platinum)
-				}
-				// Ex. Tekko Prime Gauntlets - 2.75p, 20 ducats
-				fmt.Printf("%v - %.2fp, %v ducats\n", item.I18N["en"].Name, sumPrice/float32(len(detailedInfo.Sell)), item.Ducats)
+			prices := printItemPrices(wfmClient, items, names)
+			if len(items) == 0 {
+				continue
+			}
+			screen := newRewardScreen(time.Now(), session, items, prices, drops)
+			if err := appendHistory(history, screen); err != nil {
+				log.Printf("Error adding rewards to the history: %v", err)
+			}
+			sets.printSets(os.Stdout, items, prices, names)
+			if picks != nil {
+				rewards, shown = items, screen.Time
+				fmt.Println(pickPrompt(rewards, names))
+			}
+		case input, ok := <-picks:
+			if !ok {
+				picks = nil
+				continue
+			}
+			if len(rewards) == 0 {
+				log.Printf("No reward screen to record a pick from")
+				continue
+			}
+			reward, count, err := recordPick(ownedPath, input, rewards)
+			if err != nil {
+				log.Printf("Error recording pick: %v", err)
+				continue
+			}
+			rewards = nil
+			fmt.Printf("Recorded %s, %d owned\n", names(reward), count)
+			if err := setHistoryPick(history, shown, reward.Id); err != nil {
+				log.Printf("Error recording pick in the history: %v", err)
 			}
 		case event, ok := <-watcher.Events:
 			if !ok {
@@ -99,6 +193,48 @@ func Run(filePath string, steamLibrary string) error {
 	}
 }
 
+// readLines sends every line of r to lines, closing it at the end of r.
+func readLines(r io.Reader, lines chan<- string) {
+	defer close(lines)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines <- scanner.Text()
+	}
+}
+
+// printItemPrices prints the price of every item, named by names. It
+// returns the prices of items, 0 for those without one.
+func printItemPrices(wfmClient *wfm.Client, items []wfm.Item, names func(wfm.Item) string) []float64 {
+	prices := make([]float64, len(items))
+	for i, item := range items {
+		price, err := itemPrice(wfmClient, item)
+		if err != nil {
+			log.Printf("Error: Unable to fetch price information for %v, %v\n", item.Id, err)
+			continue
+		}
+		prices[i] = price
+		// Ex. Tekko Prime Gauntlets - 2.75p, 20 ducats
+		fmt.Printf("%v - %.2fp, %v ducats\n", names(item), price, item.Ducats)
+	}
+	return prices
+}
+
+// itemPrice is the average of the top sell orders of item.
+func itemPrice(wfmClient *wfm.Client, item wfm.Item) (float64, error) {
+	detailedInfo, err := wfmClient.FetchItemTopOrders(item.Id, nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(detailedInfo.Sell) == 0 {
+		return 0, fmt.Errorf("no sell orders")
+	}
+	var sumPrice float64
+	for _, order := range detailedInfo.Sell {
+		sumPrice += float64(order.Platinum)
+	}
+	return sumPrice / float64(len(detailedInfo.Sell)), nil
+}
+
 type detectionState struct {
 	mu            sync.Mutex
 	lastTriggered time.Time
@@ -114,7 +250,19 @@ type appState struct {
 	logParser  *logParser
 	detection  *detectionState
 	foundItems chan []wfm.Item
-	ocrClient  *gosseract.Client
+	ocr        OCREngine
+	capturer   Capturer
+	detectOpts []DetectOption
+	// now is the clock used for rate limiting, defaults to time.Now.
+	now     func() time.Time
+	pending sync.WaitGroup
+}
+
+func (s *appState) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
 }
 
 func (s *appState) handleWriteEvent() {
@@ -153,21 +301,56 @@ func (s *appState) handleLine(line string, err error) {
 	s.detection.mu.Lock()
 	defer s.detection.mu.Unlock()
 
-	if time.Since(s.detection.lastTriggered) < 1*time.Minute {
+	now := s.clock()
+	if now.Sub(s.detection.lastTriggered) < 1*time.Minute {
 		return
 	}
 
-	s.detection.lastTriggered = time.Now()
-	go s.triggerDetection()
+	s.detection.lastTriggered = now
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		s.triggerDetection()
+	}()
 }
 
+const (
+	captureAttempts = 3
+	captureBackoff  = 200 * time.Millisecond
+)
+
 func (s *appState) triggerDetection() {
 	time.Sleep(500 * time.Millisecond)
-	img := screenshot()
+	img, err := captureWithRetry(s.capturer, detectionRegion(s.detectOpts...), captureAttempts, captureBackoff)
+	if err != nil {
+		log.Printf("Capture failed, skipping this reward screen: %v", err)
+		return
+	}
 
-	// img, _ := imgio.Open("internal/testdata/conquera-1.png")
 	log.Println("detecting items")
-	s.foundItems <- DetectItems(img, s.ocrClient)
+	items := DetectItems(img, s.ocr, s.detectOpts...)
+	if len(items) == 0 {
+		relocate(s.capturer)
+	}
+	s.foundItems <- items
+}
+
+// captureWithRetry tries to capture region up to attempts times, doubling the
+// delay between attempts starting at backoff.
+func captureWithRetry(capturer Capturer, region image.Rectangle, attempts int, backoff time.Duration) (image.Image, error) {
+	var err error
+	for attempt := range attempts {
+		if attempt > 0 {
+			time.Sleep(backoff << (attempt - 1))
+		}
+		var img image.Image
+		img, err = captureRegion(capturer, region)
+		if err == nil {
+			return img, nil
+		}
+		log.Printf("capture attempt %d/%d failed: %v", attempt+1, attempts, err)
+	}
+	return nil, err
 }
 
 func processLogLine(line string) bool {
@@ -177,3 +177,30 @@ func TestDetectionErrorHandling(t *testing.T) {
 		t.Error("Channel operation timed out")
 	}
 }
+
+func TestDetectionRelocatesGame(t *testing.T) {
+	engine, err := NewGlyphEngine()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	theme, err := LookupTheme("Harrier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	capturer := &fakeRelocator{}
+	app := &appState{
+		foundItems: make(chan []wfm.Item, 1),
+		ocr:        engine,
+		capturer:   capturer,
+		detectOpts: []DetectOption{WithTheme(theme)},
+	}
+
+	// A blank capture has no rewards, so the game is looked for again
+	app.triggerDetection()
+	if items := <-app.foundItems; len(items) != 0 {
+		t.Errorf("expected no items, got %v", items)
+	}
+	if capturer.relocations != 1 {
+		t.Errorf("expected the game to be relocated once, got %d", capturer.relocations)
+	}
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Binarization selects how a reward box is reduced to black text on white
+// before OCR.
+type Binarization int
+
+const (
+	// BinarizeRGB keeps pixels within the theme threshold of the text color
+	// by Euclidean RGB distance.
+	BinarizeRGB Binarization = iota
+	// BinarizeLab keeps pixels within a fixed CIELAB ΔE of the text color,
+	// which tracks perceived difference better on bright backgrounds.
+	BinarizeLab
+	// BinarizeOtsu picks the ΔE cutoff per box with Otsu's method, adapting
+	// to anti-aliasing and the relic background behind the text.
+	BinarizeOtsu
+)
+
+var binarizationNames = []string{"rgb", "lab", "otsu"}
+
+func (b Binarization) String() string {
+	if int(b) < len(binarizationNames) {
+		return binarizationNames[b]
+	}
+	return fmt.Sprintf("Binarization(%d)", int(b))
+}
+
+// ParseBinarization returns the binarization called name.
+func ParseBinarization(name string) (Binarization, error) {
+	for i, n := range binarizationNames {
+		if strings.EqualFold(n, name) {
+			return Binarization(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown binarization %q (available: %s)", name, strings.Join(binarizationNames, ", "))
+}
+
+const (
+	// labThreshold is the ΔE BinarizeLab accepts as text.
+	labThreshold = 25
+	// maxOtsuThreshold caps the Otsu cutoff so a box without text isn't
+	// split in half.
+	maxOtsuThreshold = 50
+)
+
+// binarize isolates the text of img with method.
+func binarize(img *image.RGBA, theme Theme, method Binarization) *image.RGBA {
+	switch method {
+	case BinarizeLab:
+		return isolateTargetColorLab(img, theme.TextColor, labThreshold)
+	case BinarizeOtsu:
+		return isolateTargetColorOtsu(img, theme.TextColor)
+	default:
+		return isolateTargetColor(img, theme.TextColor, theme.Threshold)
+	}
+}
+
+func isolateTargetColorLab(img *image.RGBA, target color.RGBA, threshold float64) *image.RGBA {
+	distances := labDistances(img, target)
+	return thresholdDistances(img.Bounds(), distances, threshold)
+}
+
+func isolateTargetColorOtsu(img *image.RGBA, target color.RGBA) *image.RGBA {
+	distances := labDistances(img, target)
+	hist := make([]int, 256)
+	for _, d := range distances {
+		hist[min(int(d), 255)]++
+	}
+	threshold := min(float64(otsuThreshold(hist)), maxOtsuThreshold)
+	return thresholdDistances(img.Bounds(), distances, threshold)
+}
+
+// labDistances returns the ΔE of every pixel of img from target.
+func labDistances(img *image.RGBA, target color.RGBA) []float64 {
+	t := toLab(target)
+	distances := make([]float64, len(img.Pix)/4)
+	for i := range distances {
+		p := img.Pix[i*4 : i*4+3 : i*4+3]
+		distances[i] = deltaE(t, toLab(color.RGBA{R: p[0], G: p[1], B: p[2]}))
+	}
+	return distances
+}
+
+// thresholdDistances makes pixels closer than threshold black and the rest
+// white.
+func thresholdDistances(bounds image.Rectangle, distances []float64, threshold float64) *image.RGBA {
+	dest := image.NewRGBA(bounds)
+	for i, d := range distances {
+		v := uint8(255)
+		if d < threshold {
+			v = 0
+		}
+		dest.Pix[i*4] = v
+		dest.Pix[i*4+1] = v
+		dest.Pix[i*4+2] = v
+		dest.Pix[i*4+3] = 255
+	}
+	return dest
+}
+
+// otsuThreshold returns the histogram bin that best separates hist into two
+// classes, maximizing the between-class variance.
+func otsuThreshold(hist []int) int {
+	total, sum := 0, 0.0
+	for i, n := range hist {
+		total += n
+		sum += float64(i * n)
+	}
+	var weightBelow int
+	var sumBelow, bestVariance float64
+	best := 0
+	for i, n := range hist {
+		weightBelow += n
+		if weightBelow == 0 {
+			continue
+		}
+		weightAbove := total - weightBelow
+		if weightAbove == 0 {
+			break
+		}
+		sumBelow += float64(i * n)
+		meanBelow := sumBelow / float64(weightBelow)
+		meanAbove := (sum - sumBelow) / float64(weightAbove)
+		variance := float64(weightBelow) * float64(weightAbove) * (meanBelow - meanAbove) * (meanBelow - meanAbove)
+		if variance > bestVariance {
+			bestVariance = variance
+			// Pixels at or below bin i are the lower class
+			best = i + 1
+		}
+	}
+	return best
+}
+
+// labColor is a color in CIELAB under the D65 white point.
+type labColor struct {
+	L, A, B float64
+}
+
+func toLab(c color.RGBA) labColor {
+	r, g, b := linearize(c.R), linearize(c.G), linearize(c.B)
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return labColor{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+// linearTable holds linearize for every channel value, it dominates the cost
+// of toLab otherwise.
+var linearTable = func() (table [256]float64) {
+	for v := range table {
+		c := float64(v) / 255
+		if c <= 0.04045 {
+			table[v] = c / 12.92
+		} else {
+			table[v] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return table
+}()
+
+// linearize undoes the sRGB transfer function.
+func linearize(v uint8) float64 {
+	return linearTable[v]
+}
+
+func labF(t float64) float64 {
+	const delta = 6.0 / 29
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+	return t/(3*delta*delta) + 4.0/29
+}
+
+// deltaE is the CIE76 color difference.
+func deltaE(a, b labColor) float64 {
+	dl, da, db := a.L-b.L, a.A-b.A, a.B-b.B
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/anthonynsimon/bild/transform"
+)
+
+func TestParseBinarization(t *testing.T) {
+	for _, method := range []Binarization{BinarizeRGB, BinarizeLab, BinarizeOtsu} {
+		actual, err := ParseBinarization(method.String())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual != method {
+			t.Errorf("expected %v, but got %v", method, actual)
+		}
+	}
+	if _, err := ParseBinarization("sauvola"); err == nil {
+		t.Error("expected error for unknown binarization")
+	}
+}
+
+func TestToLab(t *testing.T) {
+	testCases := []struct {
+		name     string
+		color    color.RGBA
+		expected labColor
+	}{
+		{"black", color.RGBA{A: 255}, labColor{0, 0, 0}},
+		{"white", color.RGBA{R: 255, G: 255, B: 255, A: 255}, labColor{100, 0, 0}},
+		{"red", color.RGBA{R: 255, A: 255}, labColor{53.24, 80.09, 67.20}},
+		{"blue", color.RGBA{B: 255, A: 255}, labColor{32.30, 79.19, -107.86}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := toLab(tc.color)
+			if deltaE(actual, tc.expected) > 0.05 {
+				t.Errorf("expected %+v, but got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOtsuThreshold(t *testing.T) {
+	hist := make([]int, 256)
+	// Text close to the target color, background far from it
+	for i := 2; i < 8; i++ {
+		hist[i] = 50
+	}
+	for i := 80; i < 120; i++ {
+		hist[i] = 100
+	}
+	threshold := otsuThreshold(hist)
+	if threshold < 8 || threshold > 80 {
+		t.Errorf("expected threshold between the classes, got %d", threshold)
+	}
+
+	if threshold := otsuThreshold(make([]int, 256)); threshold != 0 {
+		t.Errorf("expected 0 for an empty histogram, got %d", threshold)
+	}
+}
+
+func TestBinarize(t *testing.T) {
+	text := color.RGBA{R: 190, G: 169, B: 102, A: 255}
+	theme := Theme{Name: "Vitruvian", TextColor: text, Threshold: defaultThemeThreshold}
+
+	// Text, an anti-aliased edge half way to a bright background, and the
+	// background itself
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	img.SetRGBA(0, 0, text)
+	img.SetRGBA(1, 0, color.RGBA{R: 212, G: 200, B: 166, A: 255})
+	img.SetRGBA(2, 0, color.RGBA{R: 235, G: 232, B: 230, A: 255})
+
+	black := color.RGBA{A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	for _, method := range []Binarization{BinarizeRGB, BinarizeLab, BinarizeOtsu} {
+		t.Run(method.String(), func(t *testing.T) {
+			actual := binarize(img, theme, method)
+			if got := actual.RGBAAt(0, 0); got != black {
+				t.Errorf("expected text pixel to be black, got %v", got)
+			}
+			if got := actual.RGBAAt(2, 0); got != white {
+				t.Errorf("expected background pixel to be white, got %v", got)
+			}
+		})
+	}
+}
+
+func TestBinarizeOtsuBlankBox(t *testing.T) {
+	// Without any text the cap keeps the box from being split in half
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	for x, v := range []uint8{20, 30, 230, 240} {
+		img.SetRGBA(x, 0, color.RGBA{R: v, G: v, B: v, A: 255})
+	}
+	actual := isolateTargetColorOtsu(img, color.RGBA{R: 253, G: 132, B: 2, A: 255})
+	for x := range 4 {
+		if actual.RGBAAt(x, 0).R != 255 {
+			t.Errorf("expected pixel %d to be white", x)
+		}
+	}
+}
+
+func BenchmarkBinarize(b *testing.B) {
+	img := loadTestImage(b, "testdata/harrier-1.png")
+	theme := detectTheme(img, rewardThemeSample)
+	crop := transform.Crop(img, rewardBoxes[0])
+
+	for _, method := range []Binarization{BinarizeRGB, BinarizeLab, BinarizeOtsu} {
+		b.Run(method.String(), func(b *testing.B) {
+			for b.Loop() {
+				binarize(crop, theme, method)
+			}
+		})
+	}
+}
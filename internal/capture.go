@@ -0,0 +1,239 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Capturer grabs the image of the game that detection runs on.
+type Capturer interface {
+	Capture() (image.Image, error)
+}
+
+// RegionCapturer is implemented by capturers that can grab part of the game
+// window, saving the cost of capturing pixels detection never reads. The
+// returned image keeps window coordinates in its bounds.
+type RegionCapturer interface {
+	CaptureRegion(rect image.Rectangle) (image.Image, error)
+}
+
+// Relocator is implemented by capturers that locate the game on the screen
+// and keep its area between captures.
+type Relocator interface {
+	// Relocate forgets the located area, so the next capture looks for the
+	// game again.
+	Relocate()
+}
+
+// relocate makes capturer look for the game again, when it locates it, after
+// a detection found nothing where the game was thought to be.
+func relocate(capturer Capturer) {
+	if r, ok := capturer.(Relocator); ok {
+		r.Relocate()
+	}
+}
+
+// captureRegion captures rect when the capturer supports it, or the whole
+// window otherwise.
+func captureRegion(capturer Capturer, rect image.Rectangle) (image.Image, error) {
+	if rc, ok := capturer.(RegionCapturer); ok && !rect.Empty() {
+		return rc.CaptureRegion(rect)
+	}
+	return capturer.Capture()
+}
+
+// CaptureConfig selects and configures the screen capture backend.
+type CaptureConfig struct {
+	// Backend is the name of a registered backend, defaults to "x11".
+	Backend string
+	// Source is the file or directory read by the "file" backend.
+	Source string
+	// Window selects the game window for the "x11" backend.
+	Window WindowMatch
+	// Output is a RandR output name such as "DP-1". The "x11" backend then
+	// captures the game from that output of the root window.
+	Output string
+	// Region is the game's area of a full screen capture, backends that
+	// capture more than the game window crop to it when set.
+	Region image.Rectangle
+}
+
+const defaultCaptureBackend = "x11"
+
+type capturerFactory func(cfg CaptureConfig) (Capturer, error)
+
+var capturerFactories = map[string]capturerFactory{}
+
+// registerCapturer makes a backend available to NewCapturer. Backends call it
+// from init so adding one does not require changes elsewhere.
+func registerCapturer(name string, factory capturerFactory) {
+	capturerFactories[name] = factory
+}
+
+// NewCapturer creates the capture backend selected by cfg.
+func NewCapturer(cfg CaptureConfig) (Capturer, error) {
+	backend := cfg.Backend
+	if backend == "" {
+		backend = defaultCaptureBackend
+	}
+	factory, ok := capturerFactories[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown capture backend %q (available: %s)", backend, strings.Join(CaptureBackends(), ", "))
+	}
+	return factory(cfg)
+}
+
+// CaptureBackends lists the names of the registered capture backends.
+func CaptureBackends() []string {
+	names := make([]string, 0, len(capturerFactories))
+	for name := range capturerFactories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ParseGeometry parses an X-style geometry such as "1920x1080+2560+0" into a
+// rectangle. The offset is optional and defaults to the origin.
+func ParseGeometry(geometry string) (image.Rectangle, error) {
+	invalid := fmt.Errorf("invalid geometry %q, expected WIDTHxHEIGHT+X+Y", geometry)
+	size, offset, hasOffset := strings.Cut(geometry, "+")
+	width, height, ok := strings.Cut(size, "x")
+	if !ok {
+		return image.Rectangle{}, invalid
+	}
+	fields := []string{width, height, "0", "0"}
+	if hasOffset {
+		if fields[2], fields[3], ok = strings.Cut(offset, "+"); !ok {
+			return image.Rectangle{}, invalid
+		}
+	}
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return image.Rectangle{}, invalid
+		}
+		values[i] = value
+	}
+	w, h, x, y := values[0], values[1], values[2], values[3]
+	if w <= 0 || h <= 0 || x < 0 || y < 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid geometry %q", geometry)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// cropToOrigin copies rect out of img into a new image starting at (0, 0),
+// so detection coordinates line up with the game's own.
+func cropToOrigin(img image.Image, rect image.Rectangle) (*image.RGBA, error) {
+	if !rect.In(img.Bounds()) {
+		return nil, fmt.Errorf("region %v is outside the %v capture", rect, img.Bounds())
+	}
+	cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, rect.Min, draw.Src)
+	return cropped, nil
+}
+
+// blackLevel is the brightest channel value still considered black.
+const blackLevel = 16
+
+func isBlack(c color.RGBA) bool {
+	return c.R <= blackLevel && c.G <= blackLevel && c.B <= blackLevel
+}
+
+// isBlackFrame reports whether img is, bar a few stray pixels, entirely black,
+// which is what compositors hand back for windows they don't let us read.
+func isBlackFrame(img *image.RGBA) bool {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return true
+	}
+	// Sampling a grid is plenty to tell a black frame from a game
+	step := max(1, min(bounds.Dx(), bounds.Dy())/64)
+	samples, lit := 0, 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			samples++
+			if !isBlack(img.RGBAAt(x, y)) {
+				lit++
+			}
+		}
+	}
+	return lit*100 < samples
+}
+
+// gameAspectX and gameAspectY are the game's aspect ratio, 16:9 like the
+// 1080p screens detection reads.
+const gameAspectX, gameAspectY = 16, 9
+
+// contentBounds trims the black bars a scaled or letterboxed game leaves
+// around its picture, returning an empty rectangle if img is all black. Bars
+// are only trimmed when they're the same size on opposite sides and leave a
+// picture of the game's aspect ratio, a dark scene at the edge of the game
+// keeps img's bounds.
+func contentBounds(img *image.RGBA) image.Rectangle {
+	rowBlack := func(y, x0, x1 int) bool {
+		for x := x0; x < x1; x++ {
+			if !isBlack(img.RGBAAt(x, y)) {
+				return false
+			}
+		}
+		return true
+	}
+	colBlack := func(x, y0, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			if !isBlack(img.RGBAAt(x, y)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	bounds := img.Bounds()
+	r := bounds
+	for r.Min.Y < r.Max.Y && rowBlack(r.Min.Y, r.Min.X, r.Max.X) {
+		r.Min.Y++
+	}
+	for r.Max.Y > r.Min.Y && rowBlack(r.Max.Y-1, r.Min.X, r.Max.X) {
+		r.Max.Y--
+	}
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	for r.Min.X < r.Max.X && colBlack(r.Min.X, r.Min.Y, r.Max.Y) {
+		r.Min.X++
+	}
+	for r.Max.X > r.Min.X && colBlack(r.Max.X-1, r.Min.Y, r.Max.Y) {
+		r.Max.X--
+	}
+
+	// Centering may leave a bar a pixel wider than its opposite
+	symmetric := func(before, after int) bool {
+		return abs(before-after) <= 1
+	}
+	if r == bounds || !symmetric(r.Min.X-bounds.Min.X, bounds.Max.X-r.Max.X) || !symmetric(r.Min.Y-bounds.Min.Y, bounds.Max.Y-r.Max.Y) {
+		return bounds
+	}
+	if !isGameAspect(r) {
+		return bounds
+	}
+	return r
+}
+
+// isGameAspect reports whether r has the game's aspect ratio. The height may
+// be off by a pixel from rounding a scaled picture.
+func isGameAspect(r image.Rectangle) bool {
+	return abs(r.Dx()*gameAspectY-r.Dy()*gameAspectX) <= gameAspectX
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/anthonynsimon/bild/imgio"
+)
+
+func init() {
+	registerCapturer("file", func(cfg CaptureConfig) (Capturer, error) {
+		if cfg.Source == "" {
+			return nil, errors.New("file capture requires a source file or directory")
+		}
+		return newFileCapturer(cfg.Source)
+	})
+}
+
+// fileCapturer reads captures from disk. A single file is returned on every
+// capture, a directory hands out its images in name order, one per capture.
+type fileCapturer struct {
+	mu     sync.Mutex
+	paths  []string
+	index  int
+	repeat bool
+}
+
+func newFileCapturer(source string) (*fileCapturer, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return &fileCapturer{paths: []string{source}, repeat: true}, nil
+	}
+
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".png", ".jpg", ".jpeg":
+			paths = append(paths, filepath.Join(source, entry.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no screenshots found in %s", source)
+	}
+	slices.Sort(paths)
+	return &fileCapturer{paths: paths}, nil
+}
+
+func (f *fileCapturer) Capture() (image.Image, error) {
+	f.mu.Lock()
+	if f.index >= len(f.paths) {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("no screenshots left after %d captures", len(f.paths))
+	}
+	path := f.paths[f.index]
+	if !f.repeat {
+		f.index++
+	}
+	f.mu.Unlock()
+
+	log.Printf("using screenshot %s\n", path)
+	return imgio.Open(path)
+}
@@ -0,0 +1,304 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anthonynsimon/bild/imgio"
+	"github.com/godbus/dbus/v5"
+)
+
+func init() {
+	registerCapturer("portal", func(cfg CaptureConfig) (Capturer, error) {
+		conn, err := dbus.ConnectSessionBus()
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to session bus: %w", err)
+		}
+		return &portalCapturer{conn: conn, region: cfg.Region}, nil
+	})
+}
+
+const (
+	portalBusName       = "org.freedesktop.portal.Desktop"
+	portalObjectPath    = "/org/freedesktop/portal/desktop"
+	portalScreenshot    = "org.freedesktop.portal.Screenshot.Screenshot"
+	portalRequest       = "org.freedesktop.portal.Request"
+	portalResponse      = "Response"
+	portalTimeout       = 30 * time.Second
+	portalResponseOK    = 0
+	portalResponseAbort = 1
+)
+
+var portalTokenCounter atomic.Uint64
+
+// portalCapturer takes screenshots through the xdg-desktop-portal Screenshot
+// API, which works on Wayland compositors where X11 capture is unavailable.
+// The portal captures every output, the game's is located in the first
+// screenshot unless region selects it.
+type portalCapturer struct {
+	conn   *dbus.Conn
+	region image.Rectangle
+	// area is the located game, kept between captures until Relocate.
+	mu   sync.Mutex
+	area image.Rectangle
+}
+
+func (p *portalCapturer) Capture() (image.Image, error) {
+	path, err := p.screenshot()
+	if err != nil {
+		return nil, err
+	}
+	img, err := imgio.Open(path)
+	// The portal saves the screenshot for us, don't leave it behind
+	if err := os.Remove(path); err != nil {
+		log.Printf("Error removing portal screenshot: %v", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read portal screenshot: %w", err)
+	}
+	if !p.region.Empty() {
+		return cropToOrigin(img, p.region)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.area.Empty() || !p.area.In(img.Bounds()) {
+		area, err := locateGameOutput(img)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("located game at %dx%d+%d+%d on screen\n", area.Dx(), area.Dy(), area.Min.X, area.Min.Y)
+		p.area = area
+	}
+	return cropToOrigin(img, p.area)
+}
+
+// Relocate forgets the located game, the next capture looks for it again.
+func (p *portalCapturer) Relocate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.area = image.Rectangle{}
+}
+
+func (p *portalCapturer) Close() error {
+	return p.conn.Close()
+}
+
+// outputHeights are the heights of common 16:9 outputs, besides the height
+// of the whole screenshot, that the game's output is looked for at.
+var outputHeights = []int{720, 1080, 1440, 2160}
+
+// gameThemeSamples are where the theme is sampled on the screens read, at
+// 1080p.
+var gameThemeSamples = []themeSample{rewardThemeSample, relicGrid.theme, inventoryGrid.theme}
+
+// locateGameOutput finds the game in a screenshot of every output, trimming
+// the black bars of a scaled or letterboxed game. A 16:9 screenshot is taken
+// to be the game's output. Otherwise outputs are taken to be 16:9 and side
+// by side or stacked from the top left corner, the game's is the one showing
+// a known theme where the screen read samples it.
+func locateGameOutput(img image.Image) (image.Rectangle, error) {
+	bounds := img.Bounds()
+	if isGameAspect(bounds) {
+		return gameContent(img, bounds)
+	}
+	// Sampled many times, converted once
+	screen, err := cropToOrigin(img, bounds)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	var contents []image.Rectangle
+	for _, output := range outputCandidates(screen.Bounds()) {
+		if content, err := gameContent(screen, output); err == nil && isGameAspect(content) {
+			contents = append(contents, content)
+		}
+	}
+	// The reward screen's sample, with the accent, is the least likely to
+	// match another output by chance, so it's tried on every output first
+	for _, sample := range gameThemeSamples {
+		for _, content := range contents {
+			if detectTheme(screen, scaleSample(sample, content)).Name != "Unknown" {
+				return content.Add(bounds.Min), nil
+			}
+		}
+	}
+	return image.Rectangle{}, fmt.Errorf("unable to find the game in the %dx%d screenshot, set its area with -capture-region", bounds.Dx(), bounds.Dy())
+}
+
+// gameContent trims the black bars around the game on output of img.
+func gameContent(img image.Image, output image.Rectangle) (image.Rectangle, error) {
+	cropped, err := cropToOrigin(img, output)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	content := contentBounds(cropped)
+	if content.Empty() {
+		return image.Rectangle{}, errors.New("screen capture is black")
+	}
+	return content.Add(output.Min), nil
+}
+
+// outputCandidates lists the areas of bounds a 16:9 output of a common
+// height could cover, laid out from the top left corner of bounds and
+// aligned to an edge, after bounds itself.
+func outputCandidates(bounds image.Rectangle) []image.Rectangle {
+	candidates := []image.Rectangle{}
+	add := func(r image.Rectangle) {
+		if r.In(bounds) && !slices.Contains(candidates, r) {
+			candidates = append(candidates, r)
+		}
+	}
+	// A single output with the game letterboxed on it
+	add(bounds)
+	heights := append([]int{bounds.Dy()}, outputHeights...)
+	for _, h := range heights {
+		w := h * gameAspectX / gameAspectY
+		if w > bounds.Dx() || h > bounds.Dy() {
+			continue
+		}
+		// Outputs side by side, aligned at the top or the bottom
+		for x := bounds.Min.X; x+w <= bounds.Max.X; x += w {
+			add(image.Rect(x, bounds.Min.Y, x+w, bounds.Min.Y+h))
+			add(image.Rect(x, bounds.Max.Y-h, x+w, bounds.Max.Y))
+		}
+		add(image.Rect(bounds.Max.X-w, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+h))
+		add(image.Rect(bounds.Max.X-w, bounds.Max.Y-h, bounds.Max.X, bounds.Max.Y))
+		// Outputs stacked, aligned at the left or the right
+		for y := bounds.Min.Y; y+h <= bounds.Max.Y; y += h {
+			add(image.Rect(bounds.Min.X, y, bounds.Min.X+w, y+h))
+			add(image.Rect(bounds.Max.X-w, y, bounds.Max.X, y+h))
+		}
+	}
+	return candidates
+}
+
+// scaleSample moves sample, in 1080p coordinates, onto the game at content.
+func scaleSample(sample themeSample, content image.Rectangle) themeSample {
+	scale := func(r image.Rectangle) image.Rectangle {
+		if r.Empty() {
+			return r
+		}
+		h := content.Dy()
+		scaled := image.Rect(r.Min.X*h/1080, r.Min.Y*h/1080, r.Max.X*h/1080, r.Max.Y*h/1080)
+		// Keep at least a pixel to sample
+		scaled.Max.X = max(scaled.Max.X, scaled.Min.X+1)
+		scaled.Max.Y = max(scaled.Max.Y, scaled.Min.Y+1)
+		return scaled.Add(content.Min)
+	}
+	return themeSample{text: scale(sample.text), accent: scale(sample.accent)}
+}
+
+// screenshot asks the portal for a screenshot and returns the path of the
+// saved file once the request has completed.
+func (p *portalCapturer) screenshot() (string, error) {
+	names := p.conn.Names()
+	if len(names) == 0 {
+		return "", errors.New("not connected to the session bus")
+	}
+	token := fmt.Sprintf("wfinfo%d", portalTokenCounter.Add(1))
+	requestPath := portalRequestPath(names[0], token)
+
+	// Subscribe before calling so a fast response can't be missed
+	signals := make(chan *dbus.Signal, 1)
+	p.conn.Signal(signals)
+	defer p.conn.RemoveSignal(signals)
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(requestPath),
+		dbus.WithMatchInterface(portalRequest),
+		dbus.WithMatchMember(portalResponse),
+	}
+	if err := p.conn.AddMatchSignal(match...); err != nil {
+		return "", fmt.Errorf("unable to subscribe to portal response: %w", err)
+	}
+	defer func() { _ = p.conn.RemoveMatchSignal(match...) }()
+
+	options := map[string]dbus.Variant{
+		"handle_token": dbus.MakeVariant(token),
+		"interactive":  dbus.MakeVariant(false),
+	}
+	var handle dbus.ObjectPath
+	err := p.conn.Object(portalBusName, portalObjectPath).Call(portalScreenshot, 0, "", options).Store(&handle)
+	if err != nil {
+		return "", fmt.Errorf("portal screenshot request failed: %w", err)
+	}
+	if handle != requestPath {
+		// Portals older than 0.9 ignore handle_token
+		requestPath = handle
+		handleMatch := []dbus.MatchOption{
+			dbus.WithMatchObjectPath(handle),
+			dbus.WithMatchInterface(portalRequest),
+			dbus.WithMatchMember(portalResponse),
+		}
+		if err := p.conn.AddMatchSignal(handleMatch...); err != nil {
+			return "", fmt.Errorf("unable to subscribe to portal response: %w", err)
+		}
+		defer func() { _ = p.conn.RemoveMatchSignal(handleMatch...) }()
+	}
+
+	timeout := time.After(portalTimeout)
+	for {
+		select {
+		case signal, ok := <-signals:
+			if !ok {
+				return "", errors.New("session bus connection closed")
+			}
+			if signal.Path != requestPath || signal.Name != portalRequest+"."+portalResponse {
+				continue
+			}
+			return parsePortalResponse(signal.Body)
+		case <-timeout:
+			return "", errors.New("timed out waiting for portal screenshot")
+		}
+	}
+}
+
+// portalRequestPath is the object path the portal uses for a request made by
+// the connection with the unique name sender.
+func portalRequestPath(sender, token string) dbus.ObjectPath {
+	sender = strings.ReplaceAll(strings.TrimPrefix(sender, ":"), ".", "_")
+	return dbus.ObjectPath(portalObjectPath + "/request/" + sender + "/" + token)
+}
+
+// parsePortalResponse extracts the screenshot path from the body of a
+// Request.Response signal, (u response, a{sv} results).
+func parsePortalResponse(body []any) (string, error) {
+	if len(body) != 2 {
+		return "", fmt.Errorf("unexpected portal response %v", body)
+	}
+	code, ok := body[0].(uint32)
+	if !ok {
+		return "", fmt.Errorf("unexpected portal response code %v", body[0])
+	}
+	switch code {
+	case portalResponseOK:
+	case portalResponseAbort:
+		return "", errors.New("portal screenshot was cancelled")
+	default:
+		return "", fmt.Errorf("portal screenshot failed with response %d", code)
+	}
+
+	results, ok := body[1].(map[string]dbus.Variant)
+	if !ok {
+		return "", fmt.Errorf("unexpected portal results %v", body[1])
+	}
+	uri, ok := results["uri"].Value().(string)
+	if !ok {
+		return "", errors.New("portal response has no screenshot uri")
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid screenshot uri %q: %w", uri, err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported screenshot uri %q", uri)
+	}
+	return u.Path, nil
+}
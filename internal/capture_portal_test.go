@@ -0,0 +1,349 @@
+package internal
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// startSessionBus runs a private dbus-daemon for the test and points the
+// session bus address at it.
+func startSessionBus(t *testing.T) {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("could not start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("could not read bus address: %v", err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(address))
+}
+
+// fakePortal implements org.freedesktop.portal.Screenshot, saving img and
+// answering every request with response.
+type fakePortal struct {
+	conn     *dbus.Conn
+	dir      string
+	mu       sync.Mutex
+	img      image.Image
+	response uint32
+	saved    string
+}
+
+// setImage changes the screenshot of the next requests.
+func (f *fakePortal) setImage(img image.Image) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.img = img
+}
+
+func (f *fakePortal) Screenshot(sender dbus.Sender, parent string, options map[string]dbus.Variant) (dbus.ObjectPath, *dbus.Error) {
+	token, _ := options["handle_token"].Value().(string)
+	path := portalRequestPath(string(sender), token)
+
+	f.saved = filepath.Join(f.dir, "Screenshot.png")
+	file, err := os.Create(f.saved)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	defer func() { _ = file.Close() }()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := png.Encode(file, f.img); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		results := map[string]dbus.Variant{"uri": dbus.MakeVariant("file://" + f.saved)}
+		_ = f.conn.Emit(path, portalRequest+"."+portalResponse, f.response, results)
+	}()
+	return path, nil
+}
+
+func startFakePortal(t *testing.T, img image.Image, response uint32) *fakePortal {
+	t.Helper()
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		t.Fatalf("could not connect to session bus: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	portal := &fakePortal{conn: conn, dir: t.TempDir(), img: img, response: response}
+	if err := conn.Export(portal, portalObjectPath, "org.freedesktop.portal.Screenshot"); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(portalBusName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("could not own %s: %v", portalBusName, err)
+	}
+	return portal
+}
+
+func TestPortalCapturer(t *testing.T) {
+	startSessionBus(t)
+
+	screen := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	marker := color.RGBA{R: 10, G: 200, B: 30, A: 255}
+	screen.SetRGBA(25, 5, marker)
+	portal := startFakePortal(t, screen, portalResponseOK)
+
+	capturer, err := NewCapturer(CaptureConfig{Backend: "portal", Region: image.Rect(20, 0, 40, 20)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = capturer.(*portalCapturer).Close() }()
+
+	img, err := capturer.Capture()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 20, 20) {
+		t.Errorf("expected capture cropped to the region, got %v", img.Bounds())
+	}
+	if got := color.RGBAModel.Convert(img.At(5, 5)); got != marker {
+		t.Errorf("expected marker pixel at (5,5), got %v", got)
+	}
+	if _, err := os.Stat(portal.saved); !os.IsNotExist(err) {
+		t.Errorf("expected portal screenshot to be removed, got %v", err)
+	}
+}
+
+// desktopScreenshot is a screenshot of outputs filled gray, with the reward
+// screen's theme drawn on the one at game.
+func desktopScreenshot(t *testing.T, bounds image.Rectangle, outputs []image.Rectangle, game image.Rectangle) *image.RGBA {
+	t.Helper()
+	theme, err := LookupTheme("Harrier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(bounds)
+	for _, output := range outputs {
+		draw.Draw(img, output, image.NewUniform(color.RGBA{R: 60, G: 60, B: 60, A: 255}), image.Point{}, draw.Src)
+	}
+	if !game.Empty() {
+		sample := scaleSample(rewardThemeSample, game)
+		draw.Draw(img, sample.text, image.NewUniform(theme.TextColor), image.Point{}, draw.Src)
+		draw.Draw(img, sample.accent, image.NewUniform(theme.AccentColor), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func TestLocateGameOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		bounds   image.Rectangle
+		outputs  []image.Rectangle
+		game     image.Rectangle
+		expected image.Rectangle
+	}{
+		{
+			name:     "single output",
+			bounds:   image.Rect(0, 0, 1920, 1080),
+			outputs:  []image.Rectangle{image.Rect(0, 0, 1920, 1080)},
+			expected: image.Rect(0, 0, 1920, 1080),
+		},
+		{
+			name:     "letterboxed on a 16:10 output",
+			bounds:   image.Rect(0, 0, 1920, 1200),
+			outputs:  []image.Rectangle{image.Rect(0, 60, 1920, 1140)},
+			game:     image.Rect(0, 60, 1920, 1140),
+			expected: image.Rect(0, 60, 1920, 1140),
+		},
+		{
+			name:     "second of two outputs",
+			bounds:   image.Rect(0, 0, 3840, 1080),
+			outputs:  []image.Rectangle{image.Rect(0, 0, 3840, 1080)},
+			game:     image.Rect(1920, 0, 3840, 1080),
+			expected: image.Rect(1920, 0, 3840, 1080),
+		},
+		{
+			name:     "1080p next to 1440p",
+			bounds:   image.Rect(0, 0, 4480, 1440),
+			outputs:  []image.Rectangle{image.Rect(0, 0, 2560, 1440), image.Rect(2560, 0, 4480, 1080)},
+			game:     image.Rect(2560, 0, 4480, 1080),
+			expected: image.Rect(2560, 0, 4480, 1080),
+		},
+		{
+			name:     "stacked outputs",
+			bounds:   image.Rect(0, 0, 1920, 2160),
+			outputs:  []image.Rectangle{image.Rect(0, 0, 1920, 2160)},
+			game:     image.Rect(0, 1080, 1920, 2160),
+			expected: image.Rect(0, 1080, 1920, 2160),
+		},
+		{
+			name:    "no game shown",
+			bounds:  image.Rect(0, 0, 3840, 1080),
+			outputs: []image.Rectangle{image.Rect(0, 0, 3840, 1080)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := desktopScreenshot(t, tt.bounds, tt.outputs, tt.game)
+			actual, err := locateGameOutput(img)
+			if tt.expected.Empty() {
+				if err == nil {
+					t.Errorf("expected an error, got %v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPortalCapturerLocatesGame(t *testing.T) {
+	startSessionBus(t)
+
+	bounds := image.Rect(0, 0, 2560, 720)
+	screen := desktopScreenshot(t, bounds, []image.Rectangle{bounds}, image.Rect(1280, 0, 2560, 720))
+	portal := startFakePortal(t, screen, portalResponseOK)
+
+	capturer, err := NewCapturer(CaptureConfig{Backend: "portal"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = capturer.(*portalCapturer).Close() }()
+
+	img, err := capturer.Capture()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 1280, 720) {
+		t.Errorf("expected capture cropped to the game's output, got %v", img.Bounds())
+	}
+
+	// The located output is kept until the game is looked for again
+	portal.setImage(desktopScreenshot(t, bounds, []image.Rectangle{bounds}, image.Rectangle{}))
+	if _, err := capturer.Capture(); err != nil {
+		t.Errorf("expected the located output to be reused, got %v", err)
+	}
+	capturer.(Relocator).Relocate()
+	if _, err := capturer.Capture(); err == nil {
+		t.Error("expected the game to be looked for again")
+	}
+}
+
+func TestPortalCapturerCancelled(t *testing.T) {
+	startSessionBus(t)
+	startFakePortal(t, image.NewRGBA(image.Rect(0, 0, 1, 1)), portalResponseAbort)
+
+	capturer, err := NewCapturer(CaptureConfig{Backend: "portal"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = capturer.(*portalCapturer).Close() }()
+
+	if _, err := capturer.Capture(); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("expected cancelled error, got %v", err)
+	}
+}
+
+func TestPortalRequestPath(t *testing.T) {
+	got := portalRequestPath(":1.42", "wfinfo1")
+	want := dbus.ObjectPath("/org/freedesktop/portal/desktop/request/1_42/wfinfo1")
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestParsePortalResponse(t *testing.T) {
+	uri := map[string]dbus.Variant{"uri": dbus.MakeVariant("file:///home/tenno/Pictures/Screenshot%20A.png")}
+	testCases := []struct {
+		name     string
+		body     []any
+		expected string
+		wantErr  bool
+	}{
+		{"success", []any{uint32(0), uri}, "/home/tenno/Pictures/Screenshot A.png", false},
+		{"cancelled", []any{uint32(1), uri}, "", true},
+		{"failed", []any{uint32(2), map[string]dbus.Variant{}}, "", true},
+		{"missing uri", []any{uint32(0), map[string]dbus.Variant{}}, "", true},
+		{"non-file uri", []any{uint32(0), map[string]dbus.Variant{"uri": dbus.MakeVariant("https://example.com/a.png")}}, "", true},
+		{"malformed body", []any{"nope"}, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parsePortalResponse(tc.body)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseGeometry(t *testing.T) {
+	testCases := []struct {
+		geometry string
+		expected image.Rectangle
+		wantErr  bool
+	}{
+		{"1920x1080+2560+0", image.Rect(2560, 0, 4480, 1080), false},
+		{"1920x1080", image.Rect(0, 0, 1920, 1080), false},
+		{"1920x1080+10", image.Rectangle{}, true},
+		{"1920x1080junk", image.Rectangle{}, true},
+		{"1920x1080+0+0foo", image.Rectangle{}, true},
+		{"1920x1080+0+0+0", image.Rectangle{}, true},
+		{"1920x", image.Rectangle{}, true},
+		{"0x1080+0+0", image.Rectangle{}, true},
+		{"wide", image.Rectangle{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.geometry, func(t *testing.T) {
+			actual, err := ParseGeometry(tc.geometry)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package internal
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// fakeCapturer returns the queued results in order, then keeps returning the
+// last one.
+type fakeCapturer struct {
+	images []image.Image
+	errs   []error
+	calls  int
+}
+
+func (f *fakeCapturer) Capture() (image.Image, error) {
+	i := min(f.calls, max(len(f.images), len(f.errs))-1)
+	f.calls++
+	var img image.Image
+	var err error
+	if i < len(f.images) {
+		img = f.images[i]
+	}
+	if i < len(f.errs) {
+		err = f.errs[i]
+	}
+	return img, err
+}
+
+// fakeRegionCapturer records the regions it was asked for.
+type fakeRegionCapturer struct {
+	fakeCapturer
+	regions []image.Rectangle
+}
+
+func (f *fakeRegionCapturer) CaptureRegion(rect image.Rectangle) (image.Image, error) {
+	f.regions = append(f.regions, rect)
+	return image.NewRGBA(rect), nil
+}
+
+// fakeRelocator counts how often it was asked to locate the game again.
+type fakeRelocator struct {
+	fakeRegionCapturer
+	relocations int
+}
+
+func (f *fakeRelocator) Relocate() {
+	f.relocations++
+}
+
+func TestNewCapturer(t *testing.T) {
+	img := filepath.Join(t.TempDir(), "capture.png")
+	writeTestPNG(t, img, image.Rect(0, 0, 4, 4))
+
+	testCases := []struct {
+		name    string
+		cfg     CaptureConfig
+		wantErr bool
+	}{
+		{"default backend", CaptureConfig{}, false},
+		{"x11 backend", CaptureConfig{Backend: "x11"}, false},
+		{"file backend", CaptureConfig{Backend: "file", Source: img}, false},
+		{"file backend without source", CaptureConfig{Backend: "file"}, true},
+		{"file backend with missing source", CaptureConfig{Backend: "file", Source: img + ".missing"}, true},
+		{"unknown backend", CaptureConfig{Backend: "nope"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			capturer, err := NewCapturer(tc.cfg)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if capturer == nil {
+				t.Error("expected a capturer")
+			}
+		})
+	}
+}
+
+func TestFileCapturerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.png")
+	writeTestPNG(t, path, image.Rect(0, 0, 4, 2))
+
+	capturer, err := newFileCapturer(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A single file is returned on every capture
+	for range 3 {
+		img, err := capturer.Capture()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 4, 2) {
+			t.Errorf("unexpected bounds %v", img.Bounds())
+		}
+	}
+}
+
+func TestFileCapturerDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPNG(t, filepath.Join(dir, "b.png"), image.Rect(0, 0, 2, 1))
+	writeTestPNG(t, filepath.Join(dir, "a.png"), image.Rect(0, 0, 1, 1))
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	capturer, err := newFileCapturer(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []int{1, 2} {
+		img, err := capturer.Capture()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if img.Bounds().Dx() != want {
+			t.Errorf("expected image of width %d, got %d", want, img.Bounds().Dx())
+		}
+	}
+	if _, err := capturer.Capture(); err == nil {
+		t.Error("expected error once screenshots are exhausted")
+	}
+}
+
+func TestFileCapturerEmptyDirectory(t *testing.T) {
+	if _, err := newFileCapturer(t.TempDir()); err == nil {
+		t.Error("expected error for directory without screenshots")
+	}
+}
+
+func TestTriggerDetectionCaptureError(t *testing.T) {
+	capturer := &fakeCapturer{errs: []error{errors.New("no window")}}
+	app := &appState{
+		foundItems: make(chan []wfm.Item, 1),
+		capturer:   capturer,
+	}
+
+	app.triggerDetection()
+
+	if capturer.calls == 0 {
+		t.Error("expected the capturer to be used")
+	}
+	select {
+	case <-app.foundItems:
+		t.Error("expected no detection after a failed capture")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCaptureWithRetry(t *testing.T) {
+	want := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	testCases := []struct {
+		name      string
+		capturer  *fakeCapturer
+		wantErr   bool
+		wantCalls int
+	}{
+		{"first attempt succeeds", &fakeCapturer{images: []image.Image{want}}, false, 1},
+		{"succeeds after retry", &fakeCapturer{images: []image.Image{nil, nil, want}, errs: []error{errors.New("a"), errors.New("b"), nil}}, false, 3},
+		{"all attempts fail", &fakeCapturer{errs: []error{errors.New("no window")}}, true, 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := captureWithRetry(tc.capturer, detectionRegion(), 3, time.Millisecond)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+			} else if err != nil || img != want {
+				t.Errorf("expected captured image, got %v, %v", img, err)
+			}
+			if tc.capturer.calls != tc.wantCalls {
+				t.Errorf("expected %d capture calls, got %d", tc.wantCalls, tc.capturer.calls)
+			}
+		})
+	}
+}
+
+func TestCaptureRegion(t *testing.T) {
+	region := detectionRegion()
+	for _, rect := range append(rewardBoxes, textColorSample, accentColorSample) {
+		if !rect.In(region) {
+			t.Errorf("expected %v to be inside detection region %v", rect, region)
+		}
+	}
+
+	regional := &fakeRegionCapturer{}
+	img, err := captureRegion(regional, region)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(regional.regions) != 1 || regional.calls != 0 {
+		t.Errorf("expected a single region capture, got %v regions and %d full captures", regional.regions, regional.calls)
+	}
+	if img.Bounds() != region {
+		t.Errorf("expected bounds %v, got %v", region, img.Bounds())
+	}
+
+	full := &fakeCapturer{images: []image.Image{image.NewRGBA(image.Rect(0, 0, 1920, 1080))}}
+	if _, err := captureRegion(full, region); err != nil || full.calls != 1 {
+		t.Errorf("expected fallback to a full capture, got %d calls, %v", full.calls, err)
+	}
+}
+
+func TestIsBlackFrame(t *testing.T) {
+	black := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for i := 3; i < len(black.Pix); i += 4 {
+		black.Pix[i] = 255
+	}
+	if !isBlackFrame(black) {
+		t.Error("expected black frame")
+	}
+
+	noisy := image.NewRGBA(black.Bounds())
+	copy(noisy.Pix, black.Pix)
+	noisy.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+	if !isBlackFrame(noisy) {
+		t.Error("expected a single stray pixel to be ignored")
+	}
+
+	game := image.NewRGBA(black.Bounds())
+	copy(game.Pix, black.Pix)
+	draw.Draw(game, image.Rect(50, 20, 150, 80), image.NewUniform(color.RGBA{R: 190, G: 160, B: 80, A: 255}), image.Point{}, draw.Src)
+	if isBlackFrame(game) {
+		t.Error("expected frame with content not to be black")
+	}
+}
+
+func TestContentBounds(t *testing.T) {
+	fill := image.NewUniform(color.RGBA{R: 100, G: 100, B: 100, A: 255})
+	testCases := []struct {
+		name     string
+		bounds   image.Rectangle
+		content  image.Rectangle
+		expected image.Rectangle
+	}{
+		{"full", image.Rect(0, 0, 64, 36), image.Rect(0, 0, 64, 36), image.Rect(0, 0, 64, 36)},
+		{"letterbox", image.Rect(0, 0, 64, 48), image.Rect(0, 6, 64, 42), image.Rect(0, 6, 64, 42)},
+		{"pillarbox", image.Rect(0, 0, 80, 36), image.Rect(8, 0, 72, 36), image.Rect(8, 0, 72, 36)},
+		{"windowbox", image.Rect(0, 0, 80, 48), image.Rect(8, 6, 72, 42), image.Rect(8, 6, 72, 42)},
+		{"uneven centering", image.Rect(0, 0, 64, 47), image.Rect(0, 5, 64, 41), image.Rect(0, 5, 64, 41)},
+		{"offset bounds", image.Rect(100, 50, 180, 105), image.Rect(116, 64, 164, 91), image.Rect(116, 64, 164, 91)},
+		{"dark edge", image.Rect(0, 0, 64, 36), image.Rect(10, 0, 64, 36), image.Rect(0, 0, 64, 36)},
+		{"uneven bars", image.Rect(0, 0, 64, 48), image.Rect(0, 2, 64, 38), image.Rect(0, 0, 64, 48)},
+		{"other aspect ratio", image.Rect(0, 0, 40, 30), image.Rect(0, 5, 40, 25), image.Rect(0, 0, 40, 30)},
+		{"all black", image.Rect(0, 0, 64, 36), image.Rectangle{}, image.Rectangle{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := image.NewRGBA(tc.bounds)
+			draw.Draw(img, tc.content, fill, image.Point{}, draw.Src)
+			if actual := contentBounds(img); actual != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func writeTestPNG(t *testing.T, path string, rect image.Rectangle) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	if err := png.Encode(file, image.NewRGBA(rect)); err != nil {
+		t.Fatal(err)
+	}
+}
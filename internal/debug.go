@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// debugCandidates is how many match candidates a debug dump lists per box.
+const debugCandidates = 5
+
+// detectionDump records the intermediate results of one detection so a bad
+// read can be inspected, and its capture added to testdata. Methods on a nil
+// dump do nothing, so detection code can call them unconditionally.
+type detectionDump struct {
+	dir   string
+	about dumpReport
+	// mu guards err, boxes are dumped concurrently.
+	mu  sync.Mutex
+	err error
+}
+
+// dumpReport is written to detection.json next to the images.
+type dumpReport struct {
+	Time      time.Time    `json:"time"`
+	Theme     string       `json:"theme"`
+	TextColor string       `json:"text_color"`
+	Threshold float64      `json:"threshold"`
+	Binarize  string       `json:"binarization"`
+	Pipeline  []string     `json:"preprocessing"`
+	Boxes     []*boxReport `json:"boxes"`
+}
+
+type boxReport struct {
+	Box        int              `json:"box"`
+	Rect       string           `json:"rect"`
+	Text       string           `json:"text"`
+	Error      string           `json:"error,omitempty"`
+	Candidates []matchCandidate `json:"candidates"`
+	dump       *detectionDump
+}
+
+// newDetectionDump creates a timestamped directory for one detection under
+// dir.
+func newDetectionDump(dir string, now time.Time) (*detectionDump, error) {
+	path := filepath.Join(dir, now.Format("2006-01-02T15-04-05.000"))
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create debug directory: %w", err)
+	}
+	return &detectionDump{dir: path, about: dumpReport{Time: now, Boxes: []*boxReport{}}}, nil
+}
+
+// saveCapture saves the captured image. Region captures are placed at their
+// window coordinates so the file can be used as a full screenshot.
+func (d *detectionDump) saveCapture(img image.Image) {
+	if d == nil {
+		return
+	}
+	bounds := img.Bounds()
+	if bounds.Min != (image.Point{}) {
+		canvas := image.NewRGBA(image.Rect(0, 0, bounds.Max.X, bounds.Max.Y))
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+		draw.Draw(canvas, bounds, img, bounds.Min, draw.Src)
+		img = canvas
+	}
+	d.saveImage("capture.png", img)
+}
+
+func (d *detectionDump) setTheme(theme Theme) {
+	if d == nil {
+		return
+	}
+	d.about.Theme = theme.Name
+	d.about.TextColor = formatHexColor(theme.TextColor)
+	d.about.Threshold = theme.Threshold
+}
+
+func (d *detectionDump) setBinarization(method Binarization) {
+	if d == nil {
+		return
+	}
+	d.about.Binarize = method.String()
+}
+
+func (d *detectionDump) setPipeline(steps []PreprocessStep) {
+	if d == nil {
+		return
+	}
+	d.about.Pipeline = make([]string, len(steps))
+	for i, step := range steps {
+		d.about.Pipeline[i] = step.String()
+	}
+}
+
+// box starts the record of reward box i.
+func (d *detectionDump) box(i int, rect image.Rectangle) *boxReport {
+	if d == nil {
+		return nil
+	}
+	b := &boxReport{Box: i, Rect: rect.String(), Candidates: []matchCandidate{}, dump: d}
+	d.about.Boxes = append(d.about.Boxes, b)
+	return b
+}
+
+// write saves detection.json and returns the first error hit while dumping.
+func (d *detectionDump) write() error {
+	if d == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(d.about, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(d.dir, "detection.json"), data, 0o644); err != nil {
+		d.fail(err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *detectionDump) saveImage(name string, img image.Image) {
+	file, err := os.Create(filepath.Join(d.dir, name))
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	if err := png.Encode(file, img); err != nil {
+		d.fail(err)
+	}
+	if err := file.Close(); err != nil {
+		d.fail(err)
+	}
+}
+
+func (d *detectionDump) fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (b *boxReport) saveCrop(img image.Image) {
+	if b == nil {
+		return
+	}
+	b.dump.saveImage(fmt.Sprintf("box-%d-crop.png", b.Box), img)
+}
+
+func (b *boxReport) saveIsolated(img image.Image) {
+	if b == nil {
+		return
+	}
+	b.dump.saveImage(fmt.Sprintf("box-%d-isolated.png", b.Box), img)
+}
+
+func (b *boxReport) savePreprocessed(img image.Image) {
+	if b == nil {
+		return
+	}
+	b.dump.saveImage(fmt.Sprintf("box-%d-preprocessed.png", b.Box), img)
+}
+
+func (b *boxReport) setText(text string) {
+	if b == nil {
+		return
+	}
+	b.Text = text
+}
+
+func (b *boxReport) setError(err error) {
+	if b == nil {
+		return
+	}
+	b.Error = err.Error()
+}
+
+func (b *boxReport) setCandidates(candidates []matchCandidate) {
+	if b == nil {
+		return
+	}
+	b.Candidates = candidates
+}
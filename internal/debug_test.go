@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDetectionDump(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 14, 15, 9, 26, 535000000, time.UTC)
+	dump, err := newDetectionDump(dir, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A region capture keeps window coordinates in its bounds
+	region := image.NewRGBA(image.Rect(10, 20, 30, 40))
+	marker := color.RGBA{R: 200, G: 100, B: 50, A: 255}
+	region.SetRGBA(15, 25, marker)
+	dump.saveCapture(region)
+	dump.setTheme(Theme{Name: "Custom", TextColor: marker, Threshold: 45})
+
+	box := dump.box(0, image.Rect(10, 20, 30, 30))
+	box.saveCrop(region)
+	box.saveIsolated(region)
+	box.setText("Mag Prme\n")
+	box.setCandidates([]matchCandidate{{Name: "Mag Prime Blueprint", Score: 14}})
+	dump.box(1, image.Rect(10, 30, 30, 40)).setError(errors.New("ocr failed"))
+
+	if err := dump.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(dir, "2026-03-14T15-09-26.535")
+	for _, name := range []string{"capture.png", "box-0-crop.png", "box-0-isolated.png", "detection.json"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+
+	capture := loadTestImage(t, filepath.Join(path, "capture.png"))
+	if capture.Bounds() != image.Rect(0, 0, 30, 40) {
+		t.Errorf("expected capture padded to window coordinates, got %v", capture.Bounds())
+	}
+	if got := color.RGBAModel.Convert(capture.At(15, 25)); got != marker {
+		t.Errorf("expected marker at (15,25), got %v", got)
+	}
+
+	data, err := os.ReadFile(filepath.Join(path, "detection.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report dumpReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("could not parse report: %v", err)
+	}
+	if report.Theme != "Custom" || report.TextColor != "#c86432" || report.Threshold != 45 {
+		t.Errorf("expected custom theme #c86432 with threshold 45, got %s %s %g", report.Theme, report.TextColor, report.Threshold)
+	}
+	if len(report.Boxes) != 2 {
+		t.Fatalf("expected 2 boxes, got %d", len(report.Boxes))
+	}
+	if report.Boxes[0].Text != "Mag Prme\n" || report.Boxes[0].Candidates[0].Name != "Mag Prime Blueprint" {
+		t.Errorf("unexpected box report %+v", report.Boxes[0])
+	}
+	if report.Boxes[1].Error != "ocr failed" {
+		t.Errorf("expected box error to be recorded, got %+v", report.Boxes[1])
+	}
+}
+
+func TestNilDetectionDump(t *testing.T) {
+	var dump *detectionDump
+	dump.saveCapture(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	dump.setTheme(Theme{})
+	box := dump.box(0, image.Rect(0, 0, 1, 1))
+	box.saveCrop(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	box.setText("text")
+	if err := dump.write(); err != nil {
+		t.Errorf("expected nil dump to do nothing, got %v", err)
+	}
+}
@@ -1,74 +1,357 @@
 package internal
 
 import (
-	"bytes"
 	"image"
 	"image/color"
-	"image/png"
 	"log"
 	"math"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/anthonynsimon/bild/transform"
-	"github.com/otiai10/gosseract/v2"
 	"github.com/simon-wg/wfinfo-go/internal/wfm"
 )
 
-func DetectItems(img image.Image, client *gosseract.Client) []wfm.Item {
-	// This only works for 1080p, 4 items
-	// Good enough for the simple case
-	// (px, py, dx, dy)
-	rects := []image.Rectangle{
-		image.Rect(477, 412, 477+239, 412+50),
-		image.Rect(719, 412, 719+239, 412+50),
-		image.Rect(962, 412, 962+239, 412+50),
-		image.Rect(1204, 412, 1204+239, 412+50),
-	}
+// This only works for 1080p, 4 items
+// Good enough for the simple case
+// (px, py, dx, dy)
+var rewardBoxes = []image.Rectangle{
+	image.Rect(477, 412, 477+239, 412+50),
+	image.Rect(719, 412, 719+239, 412+50),
+	image.Rect(962, 412, 962+239, 412+50),
+	image.Rect(1204, 412, 1204+239, 412+50),
+}
 
-	textColor := detectTextColor(&img)
+// textColorSample is the strip of UI text sampled to find the theme color.
+var textColorSample = image.Rect(320, 52, 324, 82)
 
-	relicItems := getRelicItems()
-	relicItemNames := getItemNames(relicItems)
+// accentColorSample is a stroke of the R of the REWARDS title, sampled to
+// tell apart themes sharing a text color.
+var accentColorSample = image.Rect(636, 50, 639, 82)
 
-	items := make([]wfm.Item, 0, len(rects))
-	for _, rect := range rects {
-		itemName, err := detectItemInBox(&img, rect, client, textColor)
-		if err != nil {
-			log.Printf("Error detecting item in box: %v", err)
-			continue
+// themeSample is where a screen shows the theme's colors, a strip of UI text
+// and a stroke of the accent color. Screens without a known accent stroke
+// leave it empty.
+type themeSample struct {
+	text   image.Rectangle
+	accent image.Rectangle
+}
+
+// rewardThemeSample is where the reward screen shows the theme's colors.
+var rewardThemeSample = themeSample{text: textColorSample, accent: accentColorSample}
+
+// region is the part of the screen s samples.
+func (s themeSample) region() image.Rectangle {
+	return s.text.Union(s.accent)
+}
+
+// detectionRegion is the part of the screen DetectItems reads from with
+// opts, including the card artwork when icons are matched.
+func detectionRegion(opts ...DetectOption) image.Rectangle {
+	options := newDetectOptions(opts)
+	region := rewardThemeSample.region()
+	for _, rect := range rewardBoxes {
+		region = region.Union(rect)
+		if options.icons != nil {
+			region = region.Union(rewardArt(rect))
 		}
-		if itemName == nil {
-			continue
+	}
+	return region
+}
+
+// DetectOption configures DetectItems.
+type DetectOption func(*detectOptions)
+
+type detectOptions struct {
+	debugDir     string
+	theme        *Theme
+	binarization Binarization
+	pipeline     []PreprocessStep
+	language     wfm.Language
+	icons        *IconMatcher
+}
+
+func newDetectOptions(opts []DetectOption) detectOptions {
+	options := detectOptions{language: wfm.LangEN}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithIconMatcher settles reward boxes whose text matches several items about
+// equally well by comparing the card artwork with their icons.
+func WithIconMatcher(m *IconMatcher) DetectOption {
+	return func(o *detectOptions) {
+		o.icons = m
+	}
+}
+
+// WithLanguage matches the text read against item names in lang, the
+// language of the game client. Defaults to English.
+func WithLanguage(lang wfm.Language) DetectOption {
+	return func(o *detectOptions) {
+		o.language = lang
+	}
+}
+
+// WithPreprocessing runs every binarized reward box through steps, in order,
+// before OCR.
+func WithPreprocessing(steps ...PreprocessStep) DetectOption {
+	return func(o *detectOptions) {
+		o.pipeline = steps
+	}
+}
+
+// WithBinarization selects how reward boxes are binarized before OCR,
+// defaults to BinarizeRGB.
+func WithBinarization(method Binarization) DetectOption {
+	return func(o *detectOptions) {
+		o.binarization = method
+	}
+}
+
+// WithTheme uses theme instead of identifying it from the screen.
+func WithTheme(theme Theme) DetectOption {
+	return func(o *detectOptions) {
+		o.theme = &theme
+	}
+}
+
+// WithDebugDir dumps the capture, every intermediate image, the OCR text and
+// the best match candidates of each detection to a new directory in dir.
+func WithDebugDir(dir string) DetectOption {
+	return func(o *detectOptions) {
+		o.debugDir = dir
+	}
+}
+
+// DetectItems reads the reward boxes of img with engine and returns the items
+// found, in screen order.
+func DetectItems(img image.Image, engine OCREngine, opts ...DetectOption) []wfm.Item {
+	options := newDetectOptions(opts)
+	dump := startDump(img, options)
+	defer finishDump(dump)
+	theme := screenTheme(img, rewardThemeSample, options)
+	dump.setTheme(theme)
+
+	relicItems := getRelicItems(options.language)
+	relicItemNames := getItemNames(relicItems, options.language)
+	if updater, ok := engine.(wordUpdater); ok {
+		if err := updater.updateWords(relicItemNames); err != nil {
+			log.Printf("Reading with the previous item words, unable to update them: %v", err)
 		}
-		item := findBestItem(*itemName, relicItems, relicItemNames)
-		items = append(items, item)
 	}
 
+	// Read the boxes in parallel, the engine limits how many at once
+	found := make([]*wfm.Item, len(rewardBoxes))
+	var wg sync.WaitGroup
+	for i, rect := range rewardBoxes {
+		box := dump.box(i, rect)
+		wg.Go(func() {
+			itemName, err := readBoxText(&img, rect, engine, theme, options, box)
+			if err != nil {
+				box.setError(err)
+				log.Printf("Error detecting item in box: %v", err)
+				return
+			}
+			if itemName == nil {
+				return
+			}
+			candidates := topMatches(*itemName, relicItemNames, debugCandidates)
+			if len(candidates) == 0 {
+				return
+			}
+			if options.icons != nil {
+				reranked, err := options.icons.rerank(img, rewardArt(rect), candidates, relicItems, options.language)
+				if err != nil {
+					log.Printf("Error matching item icons: %v", err)
+				}
+				candidates = reranked
+			}
+			box.setCandidates(candidates)
+			item, err := getItemFromName(candidates[0].Name, relicItems, options.language)
+			if err != nil {
+				box.setError(err)
+				log.Printf("Error detecting item in box: %v", err)
+				return
+			}
+			found[i] = &item
+		})
+	}
+	wg.Wait()
+
+	items := make([]wfm.Item, 0, len(rewardBoxes))
+	for _, item := range found {
+		if item != nil {
+			items = append(items, *item)
+		}
+	}
 	return items
 }
 
-func detectItemInBox(img *image.Image, rect image.Rectangle, client *gosseract.Client, textColor color.RGBA) (*string, error) {
+// startDump starts the debug dump of reading img with options, nil when
+// options have no debug directory.
+func startDump(img image.Image, options detectOptions) *detectionDump {
+	if options.debugDir == "" {
+		return nil
+	}
+	dump, err := newDetectionDump(options.debugDir, time.Now())
+	if err != nil {
+		log.Printf("Error creating debug dump: %v", err)
+		return nil
+	}
+	dump.saveCapture(img)
+	dump.setBinarization(options.binarization)
+	dump.setPipeline(options.pipeline)
+	return dump
+}
+
+func finishDump(dump *detectionDump) {
+	if err := dump.write(); err != nil {
+		log.Printf("Error writing debug dump: %v", err)
+	} else if dump != nil {
+		log.Printf("Wrote debug dump to %s", dump.dir)
+	}
+}
+
+// screenTheme returns the theme of options, or identifies it from sample of
+// img.
+func screenTheme(img image.Image, sample themeSample, options detectOptions) Theme {
+	if options.theme != nil {
+		return *options.theme
+	}
+	theme := detectTheme(img, sample)
+	if theme.Name == "Unknown" {
+		log.Printf("Unknown UI theme with text color %s, set a custom theme if items are misread", formatHexColor(theme.TextColor))
+	}
+	return theme
+}
+
+// readBoxText reads the lines of text in rect of img joined by spaces, nil
+// when the box is empty.
+func readBoxText(img *image.Image, rect image.Rectangle, engine OCREngine, theme Theme, options detectOptions, box *boxReport) (*string, error) {
 	cropped := transform.Crop(*img, rect)
-	isolated := isolateTargetColor(cropped, textColor, 60)
-	imgBuf := new(bytes.Buffer)
-	if err := png.Encode(imgBuf, isolated); err != nil {
-		return nil, err
+	box.saveCrop(cropped)
+	isolated := binarize(cropped, theme, options.binarization)
+	box.saveIsolated(isolated)
+	if len(options.pipeline) > 0 {
+		isolated = preprocess(isolated, options.pipeline)
+		box.savePreprocessed(isolated)
 	}
-	if err := client.SetImageFromBytes(imgBuf.Bytes()); err != nil {
-		return nil, err
+	// Long names wrap, read them a line at a time
+	lines := []string{}
+	for _, line := range splitLines(isolated) {
+		text, err := engine.ReadLine(line)
+		if err != nil {
+			return nil, err
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			lines = append(lines, text)
+		}
 	}
-	text, err := client.Text()
-	if err != nil {
-		return nil, err
+	box.setText(strings.Join(lines, "\n"))
+	if len(lines) == 0 {
+		// Fewer rewards than boxes leaves some empty
+		return nil, nil
 	}
-	text = strings.TrimSpace(text)
-	text = strings.ReplaceAll(text, "\n", " ")
+	text := strings.Join(lines, " ")
 	return &text, nil
 }
 
-func detectTextColor(img *image.Image) color.RGBA {
-	rect := image.Rect(320, 52, 324, 82)
-	sample := transform.Crop(*img, rect)
+const (
+	// minLineHeight is the fewest rows a line of text spans, shorter bands
+	// of ink are noise.
+	minLineHeight = 3
+	// lineCoreShare is the percentage of the busiest row's ink a row needs to
+	// be in the body of a line rather than a descender or dot between lines.
+	lineCoreShare = 15
+)
+
+// lineSpan is a band of rows, end exclusive.
+type lineSpan struct {
+	start, end int
+}
+
+// lineCores returns the bodies of the lines of text in a binarized box, the
+// rows between x-height and baseline where nearly every letter has ink.
+// Wrapped names have descenders reaching the next line, so lines can't be
+// told apart by empty rows alone.
+func lineCores(img *image.RGBA) (cores []lineSpan, counts []int) {
+	bounds := img.Bounds()
+	counts = make([]int, bounds.Dy())
+	peak := 0
+	for y := range counts {
+		row := img.Pix[y*img.Stride : y*img.Stride+bounds.Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			if isInk(row, i) {
+				counts[y]++
+			}
+		}
+		peak = max(peak, counts[y])
+	}
+	if peak == 0 {
+		return nil, counts
+	}
+
+	dense := max(1, peak*lineCoreShare/100)
+	tallest := 0
+	for y := 0; y < len(counts); {
+		if counts[y] < dense {
+			y++
+			continue
+		}
+		start := y
+		for y < len(counts) && counts[y] >= dense {
+			y++
+		}
+		cores = append(cores, lineSpan{start, y})
+		tallest = max(tallest, y-start)
+	}
+	// Cap heights and stray marks make short bands of their own
+	return slices.DeleteFunc(cores, func(core lineSpan) bool {
+		return core.end-core.start < max(minLineHeight, tallest*2/5)
+	}), counts
+}
+
+// splitLines returns the lines of text in a binarized box, top to bottom,
+// cutting between lines at the emptiest row.
+func splitLines(img *image.RGBA) []*image.RGBA {
+	bounds := img.Bounds()
+	cores, counts := lineCores(img)
+	lines := make([]*image.RGBA, 0, len(cores))
+	top := 0
+	for i, core := range cores {
+		bottom := len(counts)
+		if i+1 < len(cores) {
+			bottom = core.end
+			for y := core.end; y < cores[i+1].start; y++ {
+				if counts[y] < counts[bottom] {
+					bottom = y
+				}
+			}
+		}
+		rect := image.Rect(bounds.Min.X, bounds.Min.Y+top, bounds.Max.X, bounds.Min.Y+bottom)
+		lines = append(lines, img.SubImage(rect).(*image.RGBA))
+		top = bottom
+	}
+	return lines
+}
+
+// detectTheme identifies the theme of img from the colors at sample.
+func detectTheme(img image.Image, sample themeSample) Theme {
+	var accent color.RGBA
+	if !sample.accent.Empty() {
+		accent = averageColor(img, sample.accent)
+	}
+	return identifyTheme(averageColor(img, sample.text), accent)
+}
+
+// averageColor is the average color of rect in img.
+func averageColor(img image.Image, rect image.Rectangle) color.RGBA {
+	sample := transform.Crop(img, rect)
 	var red, green, blue, alpha uint64
 	pixels := sample.Pix
 	for i := 0; i < len(pixels); i += 4 {
@@ -1,17 +1,20 @@
 package internal
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/anthonynsimon/bild/imgio"
-	"github.com/otiai10/gosseract/v2"
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
 )
 
-func loadTestImage(t *testing.T, path string) image.Image {
+func loadTestImage(t testing.TB, path string) image.Image {
 	file, err := os.Open(path)
 	if err != nil {
 		t.Fatalf("could not open test image: %v", err)
@@ -25,32 +28,6 @@ func loadTestImage(t *testing.T, path string) image.Image {
 	return img
 }
 
-func TestDetectTextColor(t *testing.T) {
-	testCases := []struct {
-		name          string
-		imagePath     string
-		expectedColor color.RGBA
-	}{
-		{"conquera", "testdata/conquera-1.png", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
-		{"contrast", "testdata/contrast-1.png", color.RGBA{R: 102, G: 176, B: 255, A: 255}},
-		{"equinox", "testdata/equinox-1.png", color.RGBA{R: 158, G: 159, B: 167, A: 255}},
-		{"harrier", "testdata/harrier-1.png", color.RGBA{R: 253, G: 132, B: 2, A: 255}},
-		{"legacy", "testdata/legacy-1.png", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
-		{"renewal", "testdata/renewal-1.png", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
-		{"vitruvian", "testdata/vitruvian-1.png", color.RGBA{R: 190, G: 169, B: 102, A: 255}},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			img := loadTestImage(t, tc.imagePath)
-			actualColor := detectTextColor(&img)
-			if actualColor != tc.expectedColor {
-				t.Errorf("expected color %v, but got %v", tc.expectedColor, actualColor)
-			}
-		})
-	}
-}
-
 func TestIsolateTargetColor(t *testing.T) {
 	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
 	img.Set(0, 0, color.RGBA{R: 255, G: 0, B: 0, A: 255})
@@ -72,96 +49,161 @@ func TestIsolateTargetColor(t *testing.T) {
 	}
 }
 
-func TestOCR(t *testing.T) {
-	tests := []struct {
-		name          string
-		imagePath     string
-		expectedItems []string
-	}{
-		{
-			name:      "Conquera",
-			imagePath: "testdata/conquera-1.png",
-			expectedItems: []string{
-				"Masseter Prime Handle",
-				"Epitaph Prime Barrel",
-				"Titania Prime Systems Blueprint",
-				"Trumna Prime Blueprint",
-			},
+func TestSplitLines(t *testing.T) {
+	line := ".#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#."
+	descender := ".#......................................"
+	img := binaryImage(
+		strings.Repeat(".", 40),
+		"..#.....................................",
+		line, line, line, line, line,
+		descender, descender, descender,
+		"...........................#............",
+		line, line, line, line, line,
+		strings.Repeat(".", 40),
+	)
+
+	lines := splitLines(img)
+	// The descender and the dot above the second line fill every row between
+	// the lines, the cut goes where there's least ink
+	expected := []image.Rectangle{image.Rect(0, 0, 40, 7), image.Rect(0, 7, 40, 17)}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d", len(expected), len(lines))
+	}
+	for i, line := range lines {
+		if line.Bounds() != expected[i] {
+			t.Errorf("expected line %d at %v, got %v", i, expected[i], line.Bounds())
+		}
+	}
+
+	// Bands much shorter than the text are noise
+	noisy := binaryImage(line, strings.Repeat(".", 40), line, line, line, line, line)
+	if lines := splitLines(noisy); len(lines) != 1 {
+		t.Errorf("expected a single line, got %d", len(lines))
+	}
+
+	if lines := splitLines(binaryImage("....", "....")); len(lines) != 0 {
+		t.Errorf("expected no lines in an empty box, got %d", len(lines))
+	}
+}
+
+// ocrCases are the testdata screenshots with the items they show.
+var ocrCases = []struct {
+	name          string
+	imagePath     string
+	expectedItems []string
+}{
+	{
+		name:      "Conquera",
+		imagePath: "testdata/conquera-1.png",
+		expectedItems: []string{
+			"Masseter Prime Handle",
+			"Epitaph Prime Barrel",
+			"Titania Prime Systems Blueprint",
+			"Trumna Prime Blueprint",
 		},
-		{
-			name:      "Contrast",
-			imagePath: "testdata/contrast-1.png",
-			expectedItems: []string{
-				"Burston Prime Receiver",
-				"Orthos Prime Handle",
-				"Ash Prime Neuroptics Blueprint",
-				"Sevagoth Prime Systems Blueprint",
-			},
+	},
+	{
+		name:      "Contrast",
+		imagePath: "testdata/contrast-1.png",
+		expectedItems: []string{
+			"Burston Prime Receiver",
+			"Orthos Prime Handle",
+			"Ash Prime Neuroptics Blueprint",
+			"Sevagoth Prime Systems Blueprint",
 		},
-		{
-			name:      "Equinox",
-			imagePath: "testdata/equinox-1.png",
-			expectedItems: []string{
-				"Dual Zoren Prime Handle",
-				"Bronco Prime Blueprint",
-				"Alternox Prime Barrel",
-				"Trumna Prime Blueprint",
-			},
+	},
+	{
+		name:      "Equinox",
+		imagePath: "testdata/equinox-1.png",
+		expectedItems: []string{
+			"Dual Zoren Prime Handle",
+			"Bronco Prime Blueprint",
+			"Alternox Prime Barrel",
+			"Trumna Prime Blueprint",
 		},
-		{
-			name:      "Harrier",
-			imagePath: "testdata/harrier-1.png",
-			expectedItems: []string{
-				"Grendel Prime Chassis Blueprint",
-				"Cernos Prime Grip",
-				"Bo Prime Blueprint",
-				"Quassus Prime Blueprint",
-			},
+	},
+	{
+		name:      "Harrier",
+		imagePath: "testdata/harrier-1.png",
+		expectedItems: []string{
+			"Grendel Prime Chassis Blueprint",
+			"Cernos Prime Grip",
+			"Bo Prime Blueprint",
+			"Quassus Prime Blueprint",
 		},
-		{
-			name:      "Legacy",
-			imagePath: "testdata/legacy-1.png",
-			expectedItems: []string{
-				"Hildryn Prime Systems Blueprint",
-				"Mesa Prime Blueprint",
-				"Caliban Prime Chassis Blueprint",
-				"Bronco Prime Blueprint",
-			},
+	},
+	{
+		name:      "Legacy",
+		imagePath: "testdata/legacy-1.png",
+		expectedItems: []string{
+			"Hildryn Prime Systems Blueprint",
+			"Mesa Prime Blueprint",
+			"Caliban Prime Chassis Blueprint",
+			"Bronco Prime Blueprint",
 		},
-		{
-			name:      "Renewal",
-			imagePath: "testdata/renewal-1.png",
-			expectedItems: []string{
-				"Daikyu Prime Blueprint",
-				"Acceltra Prime Receiver",
-				"Caliban Prime Chassis Blueprint",
-				"Lavos Prime Chassis Blueprint",
-			},
+	},
+	{
+		name:      "Renewal",
+		imagePath: "testdata/renewal-1.png",
+		expectedItems: []string{
+			"Daikyu Prime Blueprint",
+			"Acceltra Prime Receiver",
+			"Caliban Prime Chassis Blueprint",
+			"Lavos Prime Chassis Blueprint",
 		},
-		{
-			name:      "Vitruvian",
-			imagePath: "testdata/vitruvian-1.png",
-			expectedItems: []string{
-				"Octavia Prime Blueprint",
-				"Tenora Prime Blueprint",
-				"Octavia Prime Systems Blueprint",
-				"Harrow Prime Systems Blueprint",
-			},
+	},
+	{
+		name:      "Vitruvian",
+		imagePath: "testdata/vitruvian-1.png",
+		expectedItems: []string{
+			"Octavia Prime Blueprint",
+			"Tenora Prime Blueprint",
+			"Octavia Prime Systems Blueprint",
+			"Harrow Prime Systems Blueprint",
 		},
+	},
+}
+
+// wordsEngine records the item names it was last tuned to.
+type wordsEngine struct {
+	barEngine
+	names []string
+}
+
+func (e *wordsEngine) updateWords(names []string) error {
+	e.names = names
+	return nil
+}
+
+func TestDetectItemsUpdatesWords(t *testing.T) {
+	theme, err := LookupTheme("Harrier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := getItemNames(getRelicItems(wfm.LangEN), wfm.LangEN)
+	if len(names) == 0 {
+		t.Skip("item catalog unavailable")
+	}
+	engine := &wordsEngine{barEngine: barEngine{texts: map[image.Point]string{}}}
+	DetectItems(image.NewRGBA(image.Rect(0, 0, 1920, 1080)), engine, WithTheme(theme))
+	if !slices.Equal(engine.names, names) {
+		t.Errorf("expected the engine tuned to the item names, got %v", engine.names)
 	}
+}
 
-	for _, tt := range tests {
+func TestOCR(t *testing.T) {
+	for _, tt := range ocrCases {
 		t.Run(tt.name, func(t *testing.T) {
 			img, err := imgio.Open(tt.imagePath)
 			if err != nil {
 				t.Fatalf("Error opening image: %v", err)
 			}
-			client := gosseract.NewClient()
-			defer func() { _ = client.Close() }()
-			if err := client.SetWhitelist("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ& \n"); err != nil {
+			engine, err := NewOCREngine("", 0, "")
+			if err != nil {
 				t.Fatalf("Error configuring OCR: %v", err)
 			}
-			items := DetectItems(img, client)
+			defer func() { _ = engine.Close() }()
+			items := DetectItems(img, engine)
 
 			actualItems := make([]string, 0, len(items))
 			for _, item := range items {
@@ -204,3 +246,102 @@ func TestOCR(t *testing.T) {
 		})
 	}
 }
+
+// BenchmarkBinarizationAccuracy reads the testdata corpus with each
+// binarization and reports the share of items detected correctly. Compare
+// methods with go test -run '^$' -bench BinarizationAccuracy -benchtime 1x.
+func BenchmarkBinarizationAccuracy(b *testing.B) {
+	engine := newBenchmarkOCREngine(b, "")
+	for _, method := range []Binarization{BinarizeRGB, BinarizeLab, BinarizeOtsu} {
+		b.Run(method.String(), func(b *testing.B) {
+			reportCorpusAccuracy(b, engine, WithBinarization(method))
+		})
+	}
+}
+
+// BenchmarkPreprocessingAccuracy compares preprocessing pipelines the same
+// way as BenchmarkBinarizationAccuracy.
+func BenchmarkPreprocessingAccuracy(b *testing.B) {
+	engine := newBenchmarkOCREngine(b, "")
+	pipelines := []string{
+		"",
+		"upscale:2",
+		"upscale:3",
+		"upscale:3,denoise",
+		"upscale:3,dilate",
+		"upscale:3,erode",
+		"deskew,upscale:3,denoise",
+	}
+	for _, spec := range pipelines {
+		steps, err := ParsePipeline(spec)
+		if err != nil {
+			b.Fatalf("invalid pipeline %q: %v", spec, err)
+		}
+		name := spec
+		if name == "" {
+			name = "none"
+		}
+		b.Run(name, func(b *testing.B) {
+			reportCorpusAccuracy(b, engine, WithPreprocessing(steps...))
+		})
+	}
+}
+
+func newBenchmarkOCREngine(b *testing.B, name string) OCREngine {
+	engine, err := NewOCREngine(name, 0, "")
+	if err != nil {
+		b.Fatalf("Error configuring OCR: %v", err)
+	}
+	b.Cleanup(func() { _ = engine.Close() })
+	return engine
+}
+
+// reportCorpusAccuracy detects the items of every ocrCases screenshot with
+// opts and reports the share found as the accuracy metric.
+func reportCorpusAccuracy(b *testing.B, engine OCREngine, opts ...DetectOption) {
+	var correct, total int
+	for b.Loop() {
+		correct, total = 0, 0
+		for _, tc := range ocrCases {
+			img, err := imgio.Open(tc.imagePath)
+			if err != nil {
+				continue
+			}
+			found := map[string]bool{}
+			for _, item := range DetectItems(img, engine, opts...) {
+				found[item.I18N["en"].Name] = true
+			}
+			for _, name := range tc.expectedItems {
+				if found[name] {
+					correct++
+				}
+			}
+			total += len(tc.expectedItems)
+		}
+	}
+	if total == 0 {
+		b.Skip("no testdata screenshots found")
+	}
+	b.ReportMetric(float64(correct)/float64(total), "accuracy")
+}
+
+// BenchmarkDetectItems measures the end-to-end latency of detecting a reward
+// screen, item list lookup included, with every engine and increasing worker
+// counts.
+func BenchmarkDetectItems(b *testing.B) {
+	img := loadTestImage(b, "testdata/harrier-1.png")
+	for _, name := range OCREngines() {
+		for _, workers := range []int{1, 2, len(rewardBoxes)} {
+			b.Run(fmt.Sprintf("%s/workers=%d", name, workers), func(b *testing.B) {
+				engine, err := NewOCREngine(name, workers, "")
+				if err != nil {
+					b.Fatalf("Error configuring OCR: %v", err)
+				}
+				defer func() { _ = engine.Close() }()
+				for b.Loop() {
+					DetectItems(img, engine)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// DefaultDucatRatio is the ducats per platinum from which parts are worth
+// more to Baro Ki'Teer than on the market.
+const DefaultDucatRatio = 10
+
+// DucatConfig holds the options for RunDucats.
+type DucatConfig struct {
+	// PartsPath is a CSV file of the parts owned, rows of name and count.
+	// The owned parts file is read when it's empty.
+	PartsPath string
+	// OwnedPartsPath is the owned parts file, defaults to a file in the user
+	// data directory.
+	OwnedPartsPath string
+	// MinRatio is the ducats per platinum from which parts are traded for
+	// ducats, DefaultDucatRatio when 0.
+	MinRatio float64
+	// SellAbove is the price from which parts are sold whatever their
+	// ducats, 0 leaves it to MinRatio.
+	SellAbove float64
+	// GameLanguage is the language the names in the file are in, defaults
+	// to English.
+	GameLanguage wfm.Language
+	// DisplayLanguage is the language results are printed in, defaults to
+	// GameLanguage.
+	DisplayLanguage wfm.Language
+}
+
+// readOwnedParts reads a CSV file of part names and counts, matching the
+// names against parts in lang. A header row and a missing count, read as
+// one, are allowed.
+func readOwnedParts(r io.Reader, parts []wfm.Item, lang wfm.Language) ([]OwnedItem, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	owned := []OwnedItem{}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return owned, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSpace(record[0])
+		if name == "" {
+			continue
+		}
+		// The header is a first row naming no part, such as "name,count"
+		part, err := matchPart(name, parts, lang)
+		if errors.Is(err, errUnknownPart) && first {
+			continue
+		}
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		count := 1
+		if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+			count, err = strconv.Atoi(strings.TrimSpace(record[1]))
+			if err != nil || count < 0 {
+				line, _ := reader.FieldPos(1)
+				return nil, fmt.Errorf("line %d: invalid count %q", line, record[1])
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		owned = append(owned, OwnedItem{Item: part, Count: count})
+	}
+}
+
+// minPartScore is the share of the score of an exact match, over the
+// average length of a name and the part's, below which the name isn't taken
+// for the part. Names only sharing a word such as "Blueprint" fall below it.
+const minPartScore = 0.6
+
+// errUnknownPart is returned for names that don't match any part.
+var errUnknownPart = errors.New("unknown part")
+
+// matchPart returns the part of parts whose name in lang is closest to name,
+// ignoring case.
+func matchPart(name string, parts []wfm.Item, lang wfm.Language) (wfm.Item, error) {
+	names := getItemNames(parts, lang)
+	if len(names) == 0 {
+		return wfm.Item{}, fmt.Errorf("item catalog unavailable")
+	}
+	lower := make([]string, len(names))
+	for i, n := range names {
+		lower[i] = strings.ToLower(n)
+	}
+	query := strings.ToLower(name)
+	best := topMatches(query, lower, 1)[0]
+	length := float64(utf8.RuneCountInString(query)+utf8.RuneCountInString(best.Name)) / 2
+	if float64(best.Score) < minPartScore*matchScore*length {
+		return wfm.Item{}, fmt.Errorf("%w %q", errUnknownPart, name)
+	}
+	return getItemFromName(names[slices.Index(lower, best.Name)], parts, lang)
+}
+
+// ducatTrade is what to do with the parts of a row.
+type ducatTrade int
+
+const (
+	// tradeKeep is for parts that neither sell nor trade for ducats.
+	tradeKeep ducatTrade = iota
+	tradeDucats
+	tradeSell
+)
+
+func (t ducatTrade) String() string {
+	return [...]string{"keep", "ducats", "sell"}[t]
+}
+
+// planTrade decides between trading row for ducats and selling it. Parts
+// without a price go to Baro when they have ducats.
+func planTrade(row inventoryRow, minRatio, sellAbove float64) ducatTrade {
+	switch {
+	case row.Price <= 0 && row.Item.Ducats <= 0:
+		return tradeKeep
+	case row.Price <= 0:
+		return tradeDucats
+	case sellAbove > 0 && row.Price >= sellAbove:
+		return tradeSell
+	case float64(row.Item.Ducats)/row.Price >= minRatio:
+		return tradeDucats
+	default:
+		return tradeSell
+	}
+}
+
+// printTrades prints the trade of every row of rows and the totals of each
+// kind of trade, naming items by names.
+func printTrades(w io.Writer, rows []inventoryRow, minRatio, sellAbove float64, names func(wfm.Item) string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ITEM\tCOUNT\tPRICE\tDUCATS\tDUCATS/PLAT\tTRADE")
+	var ducatParts, ducats, sellParts int
+	var plat float64
+	for _, row := range rows {
+		price, ratio := "-", "-"
+		if row.Price > 0 {
+			price = fmt.Sprintf("%.2fp", row.Price)
+			ratio = fmt.Sprintf("%.1f", float64(row.Item.Ducats)/row.Price)
+		}
+		trade := planTrade(row, minRatio, sellAbove)
+		switch trade {
+		case tradeDucats:
+			ducatParts += row.Count
+			ducats += row.Ducats()
+		case tradeSell:
+			sellParts += row.Count
+			plat += row.Plat()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", names(row.Item), row.countText(), price, row.Item.Ducats, ratio, trade)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nTrade %d parts for %d ducats, sell %d parts for %.2fp\n", ducatParts, ducats, sellParts, plat)
+	return err
+}
+
+// ducatParts reads the parts owned from the CSV file of cfg, or from the
+// owned parts file without one.
+func ducatParts(cfg DucatConfig, parts []wfm.Item, lang wfm.Language) ([]OwnedItem, error) {
+	if cfg.PartsPath == "" {
+		path, err := ownedPartsPath(cfg.OwnedPartsPath)
+		if err != nil {
+			return nil, err
+		}
+		owned, err := loadOwnedParts(path)
+		if err != nil {
+			return nil, err
+		}
+		return ownedItems(owned, parts), nil
+	}
+	file, err := os.Open(cfg.PartsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	owned, err := readOwnedParts(file, parts, lang)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.PartsPath, err)
+	}
+	return owned, nil
+}
+
+// RunDucats reads the parts owned from a CSV file or the owned parts file,
+// and prints which to trade to Baro Ki'Teer for ducats and which to sell, by
+// their ducats per platinum.
+func RunDucats(cfg DucatConfig) error {
+	minRatio := cfg.MinRatio
+	if minRatio == 0 {
+		minRatio = DefaultDucatRatio
+	}
+	if minRatio < 0 || cfg.SellAbove < 0 {
+		return fmt.Errorf("ducat thresholds can't be negative")
+	}
+
+	lang := gameLanguage(cfg.GameLanguage)
+	parts := getRelicItems(lang)
+	if len(parts) == 0 {
+		return fmt.Errorf("item catalog unavailable")
+	}
+	owned, err := ducatParts(cfg, parts, lang)
+	if err != nil {
+		return err
+	}
+	if len(owned) == 0 {
+		return fmt.Errorf("no parts owned")
+	}
+
+	wfmClient := wfm.NewClient()
+	rows := priceInventory(owned, func(item wfm.Item) (float64, error) {
+		return itemPrice(wfmClient, item)
+	})
+	return printTrades(os.Stdout, rows, minRatio, cfg.SellAbove, displayNames(lang, cfg.DisplayLanguage))
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func TestReadOwnedParts(t *testing.T) {
+	parts := []wfm.Item{
+		inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15),
+		inventoryItem("volt_prime_neuroptics", "Volt Prime Neuroptics Blueprint", 100),
+		inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45),
+	}
+	tests := []struct {
+		name     string
+		csv      string
+		expected map[string]int
+		err      bool
+	}{
+		{
+			name:     "header",
+			csv:      "name,count\nTekko Prime Gauntlet,4\nLex Prime Barrel, 2\n",
+			expected: map[string]int{"tekko_prime_gauntlet": 4, "lex_prime_barrel": 2},
+		},
+		{
+			name:     "single column header",
+			csv:      "name\nTekko Prime Gauntlet\nLex Prime Barrel\n",
+			expected: map[string]int{"tekko_prime_gauntlet": 1, "lex_prime_barrel": 1},
+		},
+		{
+			name:     "header with a numeric column",
+			csv:      "Part,1\nTekko Prime Gauntlet,4\n",
+			expected: map[string]int{"tekko_prime_gauntlet": 4},
+		},
+		{
+			name:     "misspelled names and missing counts",
+			csv:      "volt prime neuroptics\n\nLex Prme Barel,3\nTekko Prime Gauntlet,0\n",
+			expected: map[string]int{"volt_prime_neuroptics": 1, "lex_prime_barrel": 3},
+		},
+		{
+			name: "invalid count",
+			csv:  "Tekko Prime Gauntlet,4\nLex Prime Barrel,two\n",
+			err:  true,
+		},
+		{
+			name: "unknown part",
+			csv:  "Tekko Prime Gauntlet,4\nForma Blueprint,2\n",
+			err:  true,
+		},
+		{
+			name: "negative count",
+			csv:  "Tekko Prime Gauntlet,-1\n",
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owned, err := readOwnedParts(strings.NewReader(tt.csv), parts, wfm.LangEN)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", owned)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			actual := map[string]int{}
+			for _, part := range owned {
+				actual[part.Item.Id] += part.Count
+			}
+			if len(actual) != len(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+			for id, count := range tt.expected {
+				if actual[id] != count {
+					t.Errorf("expected %d of %s, got %d", count, id, actual[id])
+				}
+			}
+		})
+	}
+}
+
+func TestPlanTrade(t *testing.T) {
+	row := func(ducats int32, price float64) inventoryRow {
+		return inventoryRow{OwnedItem: OwnedItem{Item: wfm.Item{Ducats: ducats}, Count: 1}, Price: price}
+	}
+	tests := []struct {
+		name      string
+		row       inventoryRow
+		sellAbove float64
+		expected  ducatTrade
+	}{
+		{"cheap part", row(100, 5), 0, tradeDucats},
+		{"at the ratio", row(45, 4.5), 0, tradeDucats},
+		{"valuable part", row(100, 20), 0, tradeSell},
+		{"sold above the price", row(100, 9), 8, tradeSell},
+		{"below the sell price", row(100, 5), 8, tradeDucats},
+		{"unpriced", row(45, 0), 0, tradeDucats},
+		{"forma", row(0, 0), 0, tradeKeep},
+	}
+	for _, tt := range tests {
+		if actual := planTrade(tt.row, DefaultDucatRatio, tt.sellAbove); actual != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestPrintTrades(t *testing.T) {
+	rows := []inventoryRow{
+		{OwnedItem: OwnedItem{Item: inventoryItem("volt_prime_neuroptics", "Volt Prime Neuroptics Blueprint", 100), Count: 1}, Price: 20},
+		{OwnedItem: OwnedItem{Item: inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15), Count: 6}, Price: 3},
+		{OwnedItem: OwnedItem{Item: inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45), Count: 3}, Price: 2},
+		{OwnedItem: OwnedItem{Item: inventoryItem("forma", "Forma Blueprint", 0), Count: 2}},
+	}
+	var out bytes.Buffer
+	if err := printTrades(&out, rows, DefaultDucatRatio, 0, func(item wfm.Item) string { return localizedName(item, wfm.LangEN) }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "" +
+		"ITEM                             COUNT  PRICE   DUCATS  DUCATS/PLAT  TRADE\n" +
+		"Volt Prime Neuroptics Blueprint  1      20.00p  100     5.0          sell\n" +
+		"Tekko Prime Gauntlet             6      3.00p   15      5.0          sell\n" +
+		"Lex Prime Barrel                 3      2.00p   45      22.5         ducats\n" +
+		"Forma Blueprint                  2      -       0       -            keep\n" +
+		"\n" +
+		"Trade 3 parts for 135 ducats, sell 7 parts for 38.00p\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestDucatParts(t *testing.T) {
+	parts := []wfm.Item{
+		inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15),
+		inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45),
+	}
+	path := filepath.Join(t.TempDir(), "owned-parts.json")
+	if err := (ownedParts{"lex_prime_barrel": 3}).save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	owned, err := ducatParts(DucatConfig{OwnedPartsPath: path}, parts, wfm.LangEN)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(owned) != 1 || owned[0].Item.Id != "lex_prime_barrel" || owned[0].Count != 3 {
+		t.Errorf("expected the owned parts without a CSV file, got %v", owned)
+	}
+}
@@ -0,0 +1,302 @@
+package internal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// ExportDatasets are the datasets RunExport exports.
+func ExportDatasets() []string {
+	return []string{"history", "prices", "inventory"}
+}
+
+// ExportConfig holds the options for RunExport.
+type ExportConfig struct {
+	// Dataset is one of ExportDatasets.
+	Dataset string
+	// Format is csv or json.
+	Format string
+	// OutputPath is the file written, standard output when empty.
+	OutputPath string
+	// From and To limit the history and prices to [From, To), either
+	// unbounded when zero.
+	From time.Time
+	To   time.Time
+	// Items keeps the rows naming an item containing any of them, ignoring
+	// case, by name or id. Every row is kept when empty.
+	Items []string
+	// HistoryPath and OwnedPartsPath are the history and owned parts files,
+	// defaulting to files in the user data directory.
+	HistoryPath    string
+	OwnedPartsPath string
+	// GameLanguage is the language of the item catalog, defaults to
+	// English. Exports name items in English.
+	GameLanguage wfm.Language
+}
+
+// ParseDateRange parses the bounds of a date range, each a date such as
+// 2026-10-19 in local time or an RFC 3339 time, empty when unbounded. A date
+// as the end includes all of that day.
+func ParseDateRange(from, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if from != "" {
+		if start, _, err = parseDate(from); err != nil {
+			return start, end, err
+		}
+	}
+	if to != "" {
+		var day bool
+		if end, day, err = parseDate(to); err != nil {
+			return start, end, err
+		}
+		if day {
+			end = end.AddDate(0, 0, 1)
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return start, end, fmt.Errorf("date range %s to %s is empty", from, to)
+	}
+	return start, end, nil
+}
+
+// parseDate parses s as a date or an RFC 3339 time, day is true for a date.
+func parseDate(s string) (t time.Time, day bool, err error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	return t, false, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or an RFC 3339 time", s)
+}
+
+// exportFilter selects the rows of an export.
+type exportFilter struct {
+	from, to time.Time
+	items    []string
+}
+
+func (f exportFilter) inRange(t time.Time) bool {
+	return (f.from.IsZero() || !t.Before(f.from)) && (f.to.IsZero() || t.Before(f.to))
+}
+
+func (f exportFilter) matchesItem(id, name string) bool {
+	if len(f.items) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(f.items, func(item string) bool {
+		item = strings.ToLower(item)
+		return strings.Contains(strings.ToLower(name), item) || strings.Contains(strings.ToLower(id), item)
+	})
+}
+
+// exportRow is a row of an export, as a CSV record and as a JSON value.
+type exportRow interface {
+	csvRecord() []string
+}
+
+// historyExport is the history dataset, a row per reward screen with the
+// reward earned from it.
+type historyExport struct {
+	rewardScreen
+	// Earned is the reward picked, when the pick was recorded.
+	Earned *historyReward `json:"earned,omitempty"`
+	// Best is the most valuable reward, the most a screen earned.
+	Best *historyReward `json:"best,omitempty"`
+}
+
+var historyHeader = []string{"time", "session", "relic", "tier", "rewards", "picked", "earned_id", "earned_name", "earned_price", "earned_ducats", "best_id", "best_name", "best_price", "best_ducats"}
+
+func (h historyExport) csvRecord() []string {
+	names := make([]string, len(h.Rewards))
+	for i, reward := range h.Rewards {
+		names[i] = reward.Name
+	}
+	record := []string{h.Time.Format(time.RFC3339), h.Session.Format(time.RFC3339), h.Relic, h.Tier, strings.Join(names, "|"), h.Picked}
+	for _, reward := range []*historyReward{h.Earned, h.Best} {
+		if reward == nil {
+			record = append(record, "", "", "", "")
+			continue
+		}
+		record = append(record, reward.Id, reward.Name, formatPrice(reward.Price), strconv.Itoa(int(reward.Ducats)))
+	}
+	return record
+}
+
+// priceExport is the prices dataset, a row per price recorded in the
+// history.
+type priceExport struct {
+	Time time.Time `json:"time"`
+	historyReward
+}
+
+var priceHeader = []string{"time", "id", "name", "price", "ducats"}
+
+func (p priceExport) csvRecord() []string {
+	return []string{p.Time.Format(time.RFC3339), p.Id, p.Name, formatPrice(p.Price), strconv.Itoa(int(p.Ducats))}
+}
+
+// inventoryExport is the inventory dataset, a row per part owned at its
+// current price.
+type inventoryExport struct {
+	Id     string  `json:"id"`
+	Name   string  `json:"name"`
+	Count  int     `json:"count"`
+	Price  float64 `json:"price"`
+	Ducats int32   `json:"ducats"`
+}
+
+var inventoryHeader = []string{"id", "name", "count", "price", "ducats"}
+
+func (i inventoryExport) csvRecord() []string {
+	return []string{i.Id, i.Name, strconv.Itoa(i.Count), formatPrice(i.Price), strconv.Itoa(int(i.Ducats))}
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
+
+// exportHistory returns the screens of the history kept by filter, with any
+// reward matching its items.
+func exportHistory(screens []rewardScreen, filter exportFilter) []historyExport {
+	rows := []historyExport{}
+	for _, screen := range screens {
+		if !filter.inRange(screen.Time) || !slices.ContainsFunc(screen.Rewards, func(r historyReward) bool {
+			return filter.matchesItem(r.Id, r.Name)
+		}) {
+			continue
+		}
+		row := historyExport{rewardScreen: screen}
+		if reward, ok := screen.picked(); ok {
+			row.Earned = &reward
+		}
+		if reward, ok := screen.best(); ok {
+			row.Best = &reward
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// exportPrices returns the prices recorded in screens kept by filter,
+// leaving out rewards without a price.
+func exportPrices(screens []rewardScreen, filter exportFilter) []priceExport {
+	rows := []priceExport{}
+	for _, screen := range screens {
+		if !filter.inRange(screen.Time) {
+			continue
+		}
+		for _, reward := range screen.Rewards {
+			if reward.Price > 0 && filter.matchesItem(reward.Id, reward.Name) {
+				rows = append(rows, priceExport{Time: screen.Time, historyReward: reward})
+			}
+		}
+	}
+	return rows
+}
+
+// exportInventory returns the rows of the inventory kept by filter.
+func exportInventory(rows []inventoryRow, filter exportFilter) []inventoryExport {
+	parts := []inventoryExport{}
+	for _, row := range rows {
+		name := localizedName(row.Item, wfm.LangEN)
+		if filter.matchesItem(row.Item.Id, name) {
+			parts = append(parts, inventoryExport{Id: row.Item.Id, Name: name, Count: row.Count, Price: row.Price, Ducats: row.Item.Ducats})
+		}
+	}
+	return parts
+}
+
+// writeExport writes rows to w in format, csv with a header row or json as
+// an array.
+func writeExport[T exportRow](w io.Writer, format string, header []string, rows []T) error {
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		_ = writer.Write(header)
+		for _, row := range rows {
+			_ = writer.Write(row.csvRecord())
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	default:
+		return fmt.Errorf("unknown export format %q, expected csv or json", format)
+	}
+}
+
+// RunExport writes a dataset of the history or the owned parts as CSV or
+// JSON, for analysis outside wfinfo-go.
+func RunExport(cfg ExportConfig) error {
+	if cfg.Format != "csv" && cfg.Format != "json" {
+		return fmt.Errorf("unknown export format %q, expected csv or json", cfg.Format)
+	}
+	filter := exportFilter{from: cfg.From, to: cfg.To, items: cfg.Items}
+
+	var write func(io.Writer) error
+	switch cfg.Dataset {
+	case "history", "prices":
+		path, err := historyPath(cfg.HistoryPath)
+		if err != nil {
+			return err
+		}
+		screens, err := loadHistory(path)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error {
+			if cfg.Dataset == "history" {
+				return writeExport(w, cfg.Format, historyHeader, exportHistory(screens, filter))
+			}
+			return writeExport(w, cfg.Format, priceHeader, exportPrices(screens, filter))
+		}
+	case "inventory":
+		if !cfg.From.IsZero() || !cfg.To.IsZero() {
+			return fmt.Errorf("the inventory has no dates to filter by")
+		}
+		lang := gameLanguage(cfg.GameLanguage)
+		parts := getRelicItems(lang)
+		if len(parts) == 0 {
+			return fmt.Errorf("item catalog unavailable")
+		}
+		owned, err := ducatParts(DucatConfig{OwnedPartsPath: cfg.OwnedPartsPath}, parts, lang)
+		if err != nil {
+			return err
+		}
+		wfmClient := wfm.NewClient()
+		rows := priceInventory(owned, func(item wfm.Item) (float64, error) {
+			return itemPrice(wfmClient, item)
+		})
+		write = func(w io.Writer) error {
+			return writeExport(w, cfg.Format, inventoryHeader, exportInventory(rows, filter))
+		}
+	default:
+		return fmt.Errorf("unknown dataset %q, expected one of %s", cfg.Dataset, strings.Join(ExportDatasets(), ", "))
+	}
+
+	if cfg.OutputPath == "" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(cfg.OutputPath)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDateRange(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.Local) }
+	tests := []struct {
+		from, to   string
+		start, end time.Time
+		err        bool
+	}{
+		{from: "", to: ""},
+		{from: "2026-10-18", to: "2026-10-19", start: day(18), end: day(20)},
+		{from: "2026-10-18T20:00:00Z", start: time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)},
+		{to: "2026-10-19T06:30:00+02:00", end: time.Date(2026, 10, 19, 4, 30, 0, 0, time.UTC)},
+		{from: "2026-10-20", to: "2026-10-19", err: true},
+		{from: "yesterday", err: true},
+	}
+	for _, tt := range tests {
+		start, end, err := ParseDateRange(tt.from, tt.to)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q to %q: expected an error", tt.from, tt.to)
+			}
+			continue
+		}
+		if err != nil || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%q to %q: expected %v to %v, got %v to %v, %v", tt.from, tt.to, tt.start, tt.end, start, end, err)
+		}
+	}
+}
+
+func exportScreens() []rewardScreen {
+	session := time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)
+	lex := historyReward{Id: "lex_prime_barrel", Name: "Lex Prime Barrel", Price: 5, Ducats: 45}
+	volt := historyReward{Id: "volt_prime_neuroptics", Name: "Volt Prime Neuroptics Blueprint", Price: 20, Ducats: 100}
+	forma := historyReward{Id: "forma", Name: "Forma Blueprint"}
+	return []rewardScreen{
+		{Time: session.Add(time.Minute), Session: session, Rewards: []historyReward{lex, forma}, Picked: "forma", Relic: "Lith A1 Relic", Tier: "Lith"},
+		{Time: session.Add(26 * time.Hour), Session: session.Add(25 * time.Hour), Rewards: []historyReward{volt, lex}, Tier: "Lith"},
+	}
+}
+
+func TestExportHistory(t *testing.T) {
+	screens := exportScreens()
+	tests := []struct {
+		name     string
+		filter   exportFilter
+		expected string
+	}{
+		{
+			name:   "everything",
+			filter: exportFilter{},
+			expected: "" +
+				"time,session,relic,tier,rewards,picked,earned_id,earned_name,earned_price,earned_ducats,best_id,best_name,best_price,best_ducats\n" +
+				"2026-10-18T20:01:00Z,2026-10-18T20:00:00Z,Lith A1 Relic,Lith,Lex Prime Barrel|Forma Blueprint,forma,forma,Forma Blueprint,0.00,0,lex_prime_barrel,Lex Prime Barrel,5.00,45\n" +
+				"2026-10-19T22:00:00Z,2026-10-19T21:00:00Z,,Lith,Volt Prime Neuroptics Blueprint|Lex Prime Barrel,,,,,,volt_prime_neuroptics,Volt Prime Neuroptics Blueprint,20.00,100\n",
+		},
+		{
+			name:   "date range",
+			filter: exportFilter{from: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+			expected: "" +
+				"time,session,relic,tier,rewards,picked,earned_id,earned_name,earned_price,earned_ducats,best_id,best_name,best_price,best_ducats\n" +
+				"2026-10-19T22:00:00Z,2026-10-19T21:00:00Z,,Lith,Volt Prime Neuroptics Blueprint|Lex Prime Barrel,,,,,,volt_prime_neuroptics,Volt Prime Neuroptics Blueprint,20.00,100\n",
+		},
+		{
+			name:   "item",
+			filter: exportFilter{items: []string{"FORMA"}},
+			expected: "" +
+				"time,session,relic,tier,rewards,picked,earned_id,earned_name,earned_price,earned_ducats,best_id,best_name,best_price,best_ducats\n" +
+				"2026-10-18T20:01:00Z,2026-10-18T20:00:00Z,Lith A1 Relic,Lith,Lex Prime Barrel|Forma Blueprint,forma,forma,Forma Blueprint,0.00,0,lex_prime_barrel,Lex Prime Barrel,5.00,45\n",
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := writeExport(&out, "csv", historyHeader, exportHistory(screens, tt.filter)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.name, tt.expected, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeExport(&out, "json", historyHeader, exportHistory(screens, exportFilter{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(decoded) != 2 || decoded[0]["relic"] != "Lith A1 Relic" || decoded[1]["earned"] != nil || decoded[1]["best"].(map[string]any)["id"] != "volt_prime_neuroptics" || len(decoded[1]["rewards"].([]any)) != 2 {
+		t.Errorf("unexpected JSON export %s", out.String())
+	}
+	if err := writeExport(&out, "xml", historyHeader, exportHistory(screens, exportFilter{})); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestExportPrices(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeExport(&out, "csv", priceHeader, exportPrices(exportScreens(), exportFilter{items: []string{"lex", "neuroptics"}})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "" +
+		"time,id,name,price,ducats\n" +
+		"2026-10-18T20:01:00Z,lex_prime_barrel,Lex Prime Barrel,5.00,45\n" +
+		"2026-10-19T22:00:00Z,volt_prime_neuroptics,Volt Prime Neuroptics Blueprint,20.00,100\n" +
+		"2026-10-19T22:00:00Z,lex_prime_barrel,Lex Prime Barrel,5.00,45\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestExportInventory(t *testing.T) {
+	rows := []inventoryRow{
+		{OwnedItem: OwnedItem{Item: inventoryItem("volt_prime_neuroptics", "Volt Prime Neuroptics Blueprint", 100), Count: 1}, Price: 20},
+		{OwnedItem: OwnedItem{Item: inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45), Count: 3}},
+	}
+	var out bytes.Buffer
+	if err := writeExport(&out, "json", inventoryHeader, exportInventory(rows, exportFilter{items: []string{"barrel"}})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `[
+  {
+    "id": "lex_prime_barrel",
+    "name": "Lex Prime Barrel",
+    "count": 3,
+    "price": 0,
+    "ducats": 45
+  }
+]
+`
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,293 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func init() {
+	registerOCREngine("glyph", func(cfg ocrConfig) (OCREngine, error) {
+		if !slices.Contains(glyphLanguages, cfg.lang) {
+			return nil, fmt.Errorf("the glyph engine can't read %s item names, it only knows the Latin alphabet", cfg.lang)
+		}
+		engine, err := NewGlyphEngine()
+		if err != nil {
+			return nil, err
+		}
+		if unknown := engine.unknownCharacters(cfg.words); unknown != "" {
+			return nil, fmt.Errorf("the glyph engine can't read %s, it has no templates for %q", cfg.vocabulary, unknown)
+		}
+		return engine, nil
+	})
+}
+
+// glyphLanguages are the languages written in the Latin alphabet. Accented
+// letters are read as the closest letter the templates know.
+var glyphLanguages = []wfm.Language{wfm.LangEN, wfm.LangDE, wfm.LangES, wfm.LangFR, wfm.LangIT, wfm.LangPL, wfm.LangPT}
+
+const (
+	// glyphCols and glyphRows are the size glyphs are scaled to before
+	// comparing them.
+	glyphCols = 8
+	glyphRows = 12
+	// glyphSamples is how many points per cell, along each axis, are sampled
+	// when scaling a glyph.
+	glyphSamples = 4
+	// glyphSpaceShare is the narrowest gap between words as a percentage of
+	// the x-height. Letters are a few pixels apart.
+	glyphSpaceShare = 30
+	// glyphCoreShare is the percentage of the x-height a letter covers at
+	// least.
+	glyphCoreShare = 60
+	// glyphShapeWeight scales the size and position of a glyph against its
+	// bitmap when matching.
+	glyphShapeWeight = 0.1
+)
+
+//go:embed glyphs.json
+var glyphsJSON []byte
+
+// glyphSet is the file format of glyphs.json.
+type glyphSet struct {
+	Cols      int             `json:"cols"`
+	Rows      int             `json:"rows"`
+	Templates []glyphTemplate `json:"templates"`
+}
+
+// glyphTemplate is the average look of a character of the game's font.
+type glyphTemplate struct {
+	Char string `json:"char"`
+	glyphFeatures
+}
+
+// glyphFeatures describe a glyph independent of the text size.
+type glyphFeatures struct {
+	// Shape is the width and height of the glyph and the offsets of its top
+	// and bottom from the x-height band, in x-heights.
+	Shape []float64 `json:"shape"`
+	// Bitmap is the glyph scaled to glyphCols by glyphRows, the share of ink
+	// in each cell row by row.
+	Bitmap []float64 `json:"bitmap"`
+}
+
+// glyph is a character found in a line of text.
+type glyph struct {
+	// rect is the ink of the glyph within the line.
+	rect image.Rectangle
+	// spaceBefore is set when a word ends before the glyph.
+	spaceBefore bool
+}
+
+// GlyphEngine reads item names by matching every character against templates
+// of the game's font. It needs no cgo, but only knows the glyphs seen in the
+// testdata screenshots it was trained on, and is experimental.
+type GlyphEngine struct {
+	templates []glyphTemplate
+}
+
+var loadGlyphTemplates = sync.OnceValues(func() ([]glyphTemplate, error) {
+	var set glyphSet
+	if err := json.Unmarshal(glyphsJSON, &set); err != nil {
+		return nil, fmt.Errorf("invalid glyph templates: %w", err)
+	}
+	if set.Cols != glyphCols || set.Rows != glyphRows {
+		return nil, fmt.Errorf("glyph templates are %dx%d, expected %dx%d", set.Cols, set.Rows, glyphCols, glyphRows)
+	}
+	return set.Templates, nil
+})
+
+// NewGlyphEngine creates an engine with the embedded glyph templates.
+func NewGlyphEngine() (*GlyphEngine, error) {
+	templates, err := loadGlyphTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return &GlyphEngine{templates: templates}, nil
+}
+
+// ReadLine reads line one glyph at a time. It only reads, so lines can be
+// read in parallel.
+func (e *GlyphEngine) ReadLine(line *image.RGBA) (string, error) {
+	core, glyphs := segmentGlyphs(line)
+	var text strings.Builder
+	for _, g := range glyphs {
+		if g.spaceBefore {
+			text.WriteByte(' ')
+		}
+		text.WriteString(e.match(glyphFeaturesOf(line, g.rect, core)))
+	}
+	return text.String(), nil
+}
+
+// unknownCharacters returns the characters of words without a template,
+// sorted.
+func (e *GlyphEngine) unknownCharacters(words []string) string {
+	unknown := []rune{}
+	for _, c := range itemCharacters(words) {
+		if c != ' ' && !slices.ContainsFunc(e.templates, func(t glyphTemplate) bool { return t.Char == string(c) }) {
+			unknown = append(unknown, c)
+		}
+	}
+	return string(unknown)
+}
+
+// Close does nothing, the engine holds no resources.
+func (e *GlyphEngine) Close() error {
+	return nil
+}
+
+// match returns the character whose template is nearest to features.
+func (e *GlyphEngine) match(features glyphFeatures) string {
+	best, bestDistance := "", math.Inf(1)
+	for _, template := range e.templates {
+		if d := glyphDistance(features, template.glyphFeatures); d < bestDistance {
+			best, bestDistance = template.Char, d
+		}
+	}
+	return best
+}
+
+func glyphDistance(a, b glyphFeatures) float64 {
+	var bitmap, shape float64
+	for i := range a.Bitmap {
+		d := a.Bitmap[i] - b.Bitmap[i]
+		bitmap += d * d
+	}
+	for i := range a.Shape {
+		d := a.Shape[i] - b.Shape[i]
+		shape += d * d
+	}
+	return bitmap/float64(len(a.Bitmap)) + glyphShapeWeight*shape
+}
+
+// segmentGlyphs splits a line of text into glyphs at the columns without ink,
+// and returns them with the x-height band they were measured against. Letters
+// touching each other come out as one glyph.
+func segmentGlyphs(line *image.RGBA) (lineSpan, []glyph) {
+	cores, _ := lineCores(line)
+	if len(cores) == 0 {
+		return lineSpan{}, nil
+	}
+	core := cores[0]
+	for _, c := range cores[1:] {
+		if c.end-c.start > core.end-core.start {
+			core = c
+		}
+	}
+	// Leave out what reaches in from the lines above and below
+	xHeight := core.end - core.start
+	bounds := line.Bounds()
+	top, bottom := max(0, core.start-xHeight), min(bounds.Dy(), core.end+xHeight*2/3)
+
+	inkAt := func(x, y int) bool {
+		return isInk(line.Pix, y*line.Stride+x*4)
+	}
+	var runs []image.Rectangle
+	for x := 0; x < bounds.Dx(); {
+		rect := image.Rectangle{Min: image.Pt(x, bottom), Max: image.Pt(x, top)}
+		for ; x < bounds.Dx(); x++ {
+			inked := false
+			for y := top; y < bottom; y++ {
+				if inkAt(x, y) {
+					inked = true
+					rect.Min.Y, rect.Max.Y = min(rect.Min.Y, y), max(rect.Max.Y, y+1)
+				}
+			}
+			if !inked {
+				break
+			}
+			rect.Max.X = x + 1
+		}
+		x++
+		// Dots and specks beside the x-height band aren't letters, nor are
+		// box borders reaching past the ascenders and descenders
+		if rect.Dx() == 0 || rect.Max.Y <= core.start || rect.Min.Y >= core.end || rect.Dy() > 2*xHeight {
+			continue
+		}
+		runs = append(runs, rect)
+	}
+	runs = mergeFragments(runs, core)
+
+	// Spaces are wider than the usual gap between letters, and wider than
+	// a share of the x-height for lines with few letters
+	gaps := make([]int, 0, len(runs))
+	for i := 1; i < len(runs); i++ {
+		gaps = append(gaps, runs[i].Min.X-runs[i-1].Max.X)
+	}
+	spaceGap := xHeight * glyphSpaceShare / 100
+	if len(gaps) > 0 {
+		sorted := slices.Sorted(slices.Values(gaps))
+		spaceGap = max(spaceGap, 2*sorted[len(sorted)/2]+1)
+	}
+	glyphs := make([]glyph, 0, len(runs))
+	for i, rect := range runs {
+		glyphs = append(glyphs, glyph{rect: rect, spaceBefore: i > 0 && gaps[i-1] >= spaceGap})
+	}
+	return core, glyphs
+}
+
+// mergeFragments joins runs of ink that can't be letters on their own into
+// the closest neighbour. Every letter spans most of the x-height, thin
+// strokes lost to binarization leave pieces that don't, like the arm of an r.
+func mergeFragments(runs []image.Rectangle, core lineSpan) []image.Rectangle {
+	xHeight := core.end - core.start
+	for i := 0; i < len(runs); {
+		covered := min(runs[i].Max.Y, core.end) - max(runs[i].Min.Y, core.start)
+		if covered*100 >= xHeight*glyphCoreShare || len(runs) == 1 {
+			i++
+			continue
+		}
+		// Prefer the left neighbour, the fragment is usually the end of a
+		// stroke
+		j := i - 1
+		if i == 0 || (i+1 < len(runs) && runs[i+1].Min.X-runs[i].Max.X < runs[i].Min.X-runs[i-1].Max.X) {
+			j = i + 1
+		}
+		runs[j] = runs[j].Union(runs[i])
+		runs = slices.Delete(runs, i, i+1)
+		i = min(i, j)
+	}
+	return runs
+}
+
+// glyphFeaturesOf measures the glyph at rect of line against the x-height
+// band core.
+func glyphFeaturesOf(line *image.RGBA, rect image.Rectangle, core lineSpan) glyphFeatures {
+	xHeight := float64(core.end - core.start)
+	features := glyphFeatures{
+		Shape: []float64{
+			float64(rect.Dx()) / xHeight,
+			float64(rect.Dy()) / xHeight,
+			float64(rect.Min.Y-core.start) / xHeight,
+			float64(rect.Max.Y-core.end) / xHeight,
+		},
+		Bitmap: make([]float64, glyphCols*glyphRows),
+	}
+	// Sample evenly spread points of each cell, so glyphs smaller than the
+	// grid are stretched and larger ones averaged
+	const samples = glyphSamples * glyphSamples
+	for cy := range glyphRows {
+		for cx := range glyphCols {
+			ink := 0
+			for sy := range glyphSamples {
+				y := rect.Min.Y + int((float64(cy)+(float64(sy)+0.5)/glyphSamples)*float64(rect.Dy())/glyphRows)
+				for sx := range glyphSamples {
+					x := rect.Min.X + int((float64(cx)+(float64(sx)+0.5)/glyphSamples)*float64(rect.Dx())/glyphCols)
+					if isInk(line.Pix, y*line.Stride+x*4) {
+						ink++
+					}
+				}
+			}
+			features.Bitmap[cy*glyphCols+cx] = float64(ink) / samples
+		}
+	}
+	return features
+}
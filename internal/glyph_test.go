@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anthonynsimon/bild/imgio"
+	"github.com/anthonynsimon/bild/transform"
+)
+
+var updateGlyphs = flag.Bool("update-glyphs", false, "rewrite glyphs.json from the testdata screenshots")
+
+// corpusBox is a binarized reward box of the testdata with the name it shows.
+type corpusBox struct {
+	screenshot string
+	name       string
+	img        *image.RGBA
+}
+
+// loadCorpusBoxes binarizes every reward box of the ocrCases screenshots
+// found on disk.
+func loadCorpusBoxes(t testing.TB, method Binarization) []corpusBox {
+	var boxes []corpusBox
+	for _, tc := range ocrCases {
+		img, err := imgio.Open(tc.imagePath)
+		if err != nil {
+			continue
+		}
+		theme := detectTheme(img, rewardThemeSample)
+		for i, rect := range rewardBoxes {
+			isolated := binarize(transform.Crop(img, rect), theme, method)
+			boxes = append(boxes, corpusBox{screenshot: tc.name, name: tc.expectedItems[i], img: isolated})
+		}
+	}
+	if len(boxes) == 0 {
+		t.Skip("no testdata screenshots found")
+	}
+	return boxes
+}
+
+// labelGlyphs pairs the glyphs of a box with the letters of its name. It
+// fails when the words and letters found don't line up with the name.
+func labelGlyphs(box corpusBox) (chars []string, features []glyphFeatures, ok bool) {
+	var words [][]glyphFeatures
+	for _, line := range splitLines(box.img) {
+		core, glyphs := segmentGlyphs(line)
+		for i, g := range glyphs {
+			// Lines end words too
+			if i == 0 || g.spaceBefore {
+				words = append(words, nil)
+			}
+			words[len(words)-1] = append(words[len(words)-1], glyphFeaturesOf(line, g.rect, core))
+		}
+	}
+	names := strings.Fields(box.name)
+	if len(words) != len(names) {
+		return nil, nil, false
+	}
+	for i, word := range words {
+		letters := strings.Split(names[i], "")
+		if len(letters) != len(word) {
+			return nil, nil, false
+		}
+		chars = append(chars, letters...)
+		features = append(features, word...)
+	}
+	return chars, features, true
+}
+
+// trainGlyphs averages the glyphs of every box that lines up with its name
+// into one template per character.
+func trainGlyphs(boxes []corpusBox) []glyphTemplate {
+	samples := map[string][]glyphFeatures{}
+	for _, box := range boxes {
+		chars, features, ok := labelGlyphs(box)
+		if !ok {
+			continue
+		}
+		for i, char := range chars {
+			samples[char] = append(samples[char], features[i])
+		}
+	}
+	round := func(v float64) float64 {
+		return math.Round(v*1000) / 1000
+	}
+	templates := make([]glyphTemplate, 0, len(samples))
+	for _, char := range slices.Sorted(func(yield func(string) bool) {
+		for char := range samples {
+			if !yield(char) {
+				return
+			}
+		}
+	}) {
+		average := glyphFeatures{Shape: make([]float64, 4), Bitmap: make([]float64, glyphCols*glyphRows)}
+		for _, sample := range samples[char] {
+			for i, v := range sample.Shape {
+				average.Shape[i] += v
+			}
+			for i, v := range sample.Bitmap {
+				average.Bitmap[i] += v
+			}
+		}
+		n := float64(len(samples[char]))
+		for i := range average.Shape {
+			average.Shape[i] = round(average.Shape[i] / n)
+		}
+		for i := range average.Bitmap {
+			average.Bitmap[i] = round(average.Bitmap[i] / n)
+		}
+		templates = append(templates, glyphTemplate{Char: char, glyphFeatures: average})
+	}
+	return templates
+}
+
+// readBox reads every line of a box with engine.
+func readBox(t testing.TB, engine OCREngine, img *image.RGBA) string {
+	lines := []string{}
+	for _, line := range splitLines(img) {
+		text, err := engine.ReadLine(line)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lines = append(lines, text)
+	}
+	return strings.Join(lines, " ")
+}
+
+// corpusNames lists the distinct names shown in boxes, standing in for the
+// item catalog.
+func corpusNames(boxes []corpusBox) []string {
+	names := []string{}
+	for _, box := range boxes {
+		names = append(names, box.name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// encodeGlyphSet formats templates as glyphs.json, a template per line so
+// retraining gives readable diffs.
+func encodeGlyphSet(templates []glyphTemplate) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "{\"cols\": %d, \"rows\": %d, \"templates\": [\n", glyphCols, glyphRows)
+	for i, template := range templates {
+		data, err := json.Marshal(template)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		if i < len(templates)-1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("]}\n")
+	return buf.Bytes(), nil
+}
+
+// TestGlyphTemplates checks glyphs.json is what the testdata trains. Rewrite
+// it after changing the testdata or the features with
+// go test ./internal -run GlyphTemplates -update-glyphs.
+func TestGlyphTemplates(t *testing.T) {
+	data, err := encodeGlyphSet(trainGlyphs(loadCorpusBoxes(t, BinarizeRGB)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *updateGlyphs {
+		if err := os.WriteFile("glyphs.json", data, 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if !bytes.Equal(data, glyphsJSON) {
+		t.Error("glyphs.json is out of date, rerun with -update-glyphs")
+	}
+}
+
+// minGlyphAccuracy is the share of boxes the glyph engine must match to the
+// right name when reading a screenshot it wasn't trained on.
+const minGlyphAccuracy = 0.9
+
+// TestGlyphEngineLeaveOneOut trains on all screenshots but one and reads the
+// one left out, matching against the names of the corpus.
+func TestGlyphEngineLeaveOneOut(t *testing.T) {
+	boxes := loadCorpusBoxes(t, BinarizeRGB)
+	names := corpusNames(boxes)
+	correct := 0
+	for _, tc := range ocrCases {
+		var train, test []corpusBox
+		for _, box := range boxes {
+			if box.screenshot == tc.name {
+				test = append(test, box)
+			} else {
+				train = append(train, box)
+			}
+		}
+		engine := &GlyphEngine{templates: trainGlyphs(train)}
+		for _, box := range test {
+			text := readBox(t, engine, box.img)
+			if match := topMatches(text, names, 1)[0].Name; match == box.name {
+				correct++
+			} else {
+				t.Logf("%s: read %q as %q, expected %q", tc.name, text, match, box.name)
+			}
+		}
+	}
+	if accuracy := float64(correct) / float64(len(boxes)); accuracy < minGlyphAccuracy {
+		t.Errorf("expected accuracy of at least %g, got %g", minGlyphAccuracy, accuracy)
+	}
+}
+
+func TestGlyphEngine(t *testing.T) {
+	engine, err := NewGlyphEngine()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	boxes := loadCorpusBoxes(t, BinarizeRGB)
+	names := corpusNames(boxes)
+	for _, box := range boxes {
+		text := readBox(t, engine, box.img)
+		if match := topMatches(text, names, 1)[0].Name; match != box.name {
+			t.Errorf("%s: read %q as %q, expected %q", box.screenshot, text, match, box.name)
+		}
+	}
+
+	text, err := engine.ReadLine(binaryImage("....", "...."))
+	if err != nil || text != "" {
+		t.Errorf("expected no text in an empty line, got %q, %v", text, err)
+	}
+}
+
+func TestSegmentGlyphs(t *testing.T) {
+	line := binaryImage(
+		"#.............................................",
+		"#.............................................",
+		"#.............................................",
+		"#..............#..............................",
+		"#..............#..............................",
+		"#..............#..............................",
+		"#.###..###..#..#...###.###.#......###.....###.",
+		"#.#.#..#.#..#..#...#.#.#.#........#.#.....#.#.",
+		"#.#.#..#.#..#..#...#.#.#.#........#.#.....#.#.",
+		"#.#.#..#.#..#..#...#.#.#.#........#.#.....#.#.",
+		"#.#.#..#.#..#..#...#.#.#.#........#.#.....#.#.",
+		"#.###..###..#####..###.###........###.....###.",
+		"#.............................................",
+		"#.............................................",
+		"#.............................................",
+		"#.............................................",
+	)
+	core, glyphs := segmentGlyphs(line)
+	if core != (lineSpan{6, 12}) {
+		t.Errorf("expected the x-height band at rows 6 to 12, got %v", core)
+	}
+	// The border is too tall to be a letter and the speck beside the fifth
+	// glyph is part of it
+	expected := []glyph{
+		{rect: image.Rect(2, 6, 5, 12)},
+		{rect: image.Rect(7, 6, 10, 12)},
+		{rect: image.Rect(12, 3, 17, 12)},
+		{rect: image.Rect(19, 6, 22, 12)},
+		{rect: image.Rect(23, 6, 28, 12)},
+		{rect: image.Rect(34, 6, 37, 12), spaceBefore: true},
+		{rect: image.Rect(42, 6, 45, 12), spaceBefore: true},
+	}
+	if !slices.Equal(glyphs, expected) {
+		t.Errorf("expected glyphs %v, got %v", expected, glyphs)
+	}
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// itemGrid is a screen of item tiles at 1080p, like rewardBoxes, each named
+// under its icon with the count owned in a corner. Only the tiles on screen
+// are read, scroll to read more.
+type itemGrid struct {
+	// origin is the top left corner of the first tile, and spacing the
+	// distance between tiles.
+	origin  image.Point
+	spacing image.Point
+	cols    int
+	rows    int
+	// name and count are the boxes of the name, which wraps to two lines,
+	// and the count within a tile.
+	name  image.Rectangle
+	count image.Rectangle
+	// theme is where the screen shows the theme's colors.
+	theme themeSample
+}
+
+// tiles returns the tiles of g in reading order.
+func (g itemGrid) tiles() []image.Rectangle {
+	tiles := make([]image.Rectangle, 0, g.cols*g.rows)
+	for row := range g.rows {
+		for col := range g.cols {
+			origin := g.origin.Add(image.Pt(col*g.spacing.X, row*g.spacing.Y))
+			tiles = append(tiles, image.Rectangle{Min: origin, Max: origin.Add(g.spacing)})
+		}
+	}
+	return tiles
+}
+
+func (g itemGrid) nameBox(tile image.Rectangle) image.Rectangle {
+	return g.name.Add(tile.Min)
+}
+
+func (g itemGrid) countBox(tile image.Rectangle) image.Rectangle {
+	return g.count.Add(tile.Min)
+}
+
+// region is the part of the screen scanGrid reads from.
+func (g itemGrid) region() image.Rectangle {
+	region := g.theme.region()
+	for _, tile := range g.tiles() {
+		region = region.Union(tile)
+	}
+	return region
+}
+
+// OwnedItem is an item of a grid screen and how many of it are owned.
+type OwnedItem struct {
+	Item  wfm.Item
+	Count int
+	// CountUnknown is set when the count of the tile couldn't be read, Count
+	// is then the single item known to be there.
+	CountUnknown bool
+}
+
+// countText is the count of o, marked with a question mark when unknown.
+func (o OwnedItem) countText() string {
+	if o.CountUnknown {
+		return fmt.Sprintf("%d?", o.Count)
+	}
+	return strconv.Itoa(o.Count)
+}
+
+// gridWords is the vocabulary of a grid of items, their names in lang and
+// the digits of counts.
+func gridWords(items []wfm.Item, lang wfm.Language) []string {
+	return append(getItemNames(items, lang), "x0123456789")
+}
+
+// scanGrid reads the tiles of grid in img with engine, matching their names
+// against items, and returns them in screen order. The debug dump records
+// the name of each tile.
+func scanGrid(img image.Image, engine OCREngine, grid itemGrid, items []wfm.Item, options detectOptions) []OwnedItem {
+	dump := startDump(img, options)
+	defer finishDump(dump)
+	theme := screenTheme(img, grid.theme, options)
+	dump.setTheme(theme)
+
+	names := getItemNames(items, options.language)
+	tiles := grid.tiles()
+	found := make([]*OwnedItem, len(tiles))
+	var wg sync.WaitGroup
+	for i, tile := range tiles {
+		nameBox := grid.nameBox(tile)
+		box := dump.box(i, nameBox)
+		wg.Go(func() {
+			name, err := readBoxText(&img, nameBox, engine, theme, options, box)
+			if err != nil {
+				box.setError(err)
+				log.Printf("Error reading tile name: %v", err)
+				return
+			}
+			if name == nil {
+				// The grid ends before the last tile
+				return
+			}
+			candidates := topMatches(*name, names, debugCandidates)
+			if len(candidates) == 0 {
+				return
+			}
+			box.setCandidates(candidates)
+
+			item, err := getItemFromName(candidates[0].Name, items, options.language)
+			if err != nil {
+				box.setError(err)
+				log.Printf("Error reading tile name: %v", err)
+				return
+			}
+			owned := OwnedItem{Item: item}
+			count, err := readBoxText(&img, grid.countBox(tile), engine, theme, options, nil)
+			if err == nil {
+				owned.Count, err = parseCount(count)
+			}
+			if err != nil {
+				log.Printf("Error reading the count of %s: %v", candidates[0].Name, err)
+				owned.Count, owned.CountUnknown = 1, true
+			}
+			found[i] = &owned
+		})
+	}
+	wg.Wait()
+
+	owned := make([]OwnedItem, 0, len(tiles))
+	for _, item := range found {
+		if item != nil {
+			owned = append(owned, *item)
+		}
+	}
+	return owned
+}
+
+// captureGrid captures grid once with the capture and OCR settings of cfg,
+// and reads it as a screen of items named vocabulary.
+func captureGrid(cfg Config, grid itemGrid, vocabulary string, items []wfm.Item) ([]OwnedItem, error) {
+	capturer, err := NewCapturer(cfg.Capture)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := capturer.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				log.Printf("Error closing capturer: %v", err)
+			}
+		}()
+	}
+
+	lang := gameLanguage(cfg.GameLanguage)
+	ocr, err := NewOCREngine(cfg.OCREngine, cfg.OCRWorkers, lang, WithVocabulary(vocabulary, gridWords(items, lang)))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := ocr.Close(); err != nil {
+			log.Printf("Error closing OCR engine: %v", err)
+		}
+	}()
+
+	img, err := captureWithRetry(capturer, grid.region(), captureAttempts, captureBackoff)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("reading %s", vocabulary)
+	options := newDetectOptions(append([]DetectOption{WithLanguage(lang)}, cfg.Detect...))
+	owned := scanGrid(img, ocr, grid, items, options)
+	if len(owned) == 0 {
+		relocate(capturer)
+	}
+	return owned, nil
+}
+
+// parseCount returns the number in the count text of a tile, such as "x12".
+// A tile without a count holds a single item.
+func parseCount(text *string) (int, error) {
+	if text == nil {
+		return 1, nil
+	}
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, *text)
+	count, err := strconv.Atoi(digits)
+	if err != nil || count < 1 {
+		return 0, fmt.Errorf("invalid count %q", *text)
+	}
+	return count, nil
+}
@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// barEngine reads a line as the text its bars stand for, by the width of
+// the line and how many columns have ink.
+type barEngine struct {
+	texts map[image.Point]string
+	mu    sync.Mutex
+	reads int
+}
+
+func (e *barEngine) ReadLine(line *image.RGBA) (string, error) {
+	e.mu.Lock()
+	e.reads++
+	e.mu.Unlock()
+	bounds := line.Bounds()
+	columns := 0
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if isInk(line.Pix, line.PixOffset(x, y)) {
+				columns++
+				break
+			}
+		}
+	}
+	if columns == 0 {
+		return "", nil
+	}
+	text, ok := e.texts[image.Pt(bounds.Dx(), columns)]
+	if !ok {
+		return "", fmt.Errorf("no text for a %d wide line with %d columns of ink", bounds.Dx(), columns)
+	}
+	return text, nil
+}
+
+func (e *barEngine) Close() error {
+	return nil
+}
+
+// drawBar draws a bar of width in color at the left of box.
+func drawBar(img draw.Image, box image.Rectangle, width int, c color.Color) {
+	bar := image.Rect(box.Min.X+2, box.Min.Y+5, box.Min.X+2+width, box.Min.Y+20)
+	draw.Draw(img, bar, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func TestScanGrid(t *testing.T) {
+	theme, err := LookupTheme("Harrier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relic := func(name string) wfm.Item {
+		return wfm.Item{Id: name, Tags: []string{"relic"}, I18N: map[string]*wfm.ItemI18N{"en": {Name: name}}}
+	}
+	relics := []wfm.Item{relic("Lith A1 Relic"), relic("Lith A2 Relic"), relic("Axi V8 Relic"), relic("Neo N5 Relic")}
+
+	img := image.NewRGBA(image.Rect(0, 0, 1920, 1080))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	// The theme is identified from the grid's own sample
+	draw.Draw(img, relicGrid.theme.text, image.NewUniform(theme.TextColor), image.Point{}, draw.Src)
+	engine := &barEngine{texts: map[image.Point]string{}}
+	tiles := relicGrid.tiles()
+	// The text read from each tile, with the count in its corner
+	layout := []struct {
+		name  string
+		count string
+	}{
+		{"Axi V8 Relic", "x3"},
+		{"Lith A2 Rellc", "x12"},
+		{"Neo N5 Relic", ""},
+		{"Lith A1 Relic", "xl"},
+	}
+	for i, tile := range layout {
+		nameBox := relicGrid.nameBox(tiles[i])
+		drawBar(img, nameBox, 20+10*i, theme.TextColor)
+		engine.texts[image.Pt(nameBox.Dx(), 20+10*i)] = tile.name
+		if tile.count != "" {
+			countBox := relicGrid.countBox(tiles[i])
+			drawBar(img, countBox, 5+5*i, theme.TextColor)
+			engine.texts[image.Pt(countBox.Dx(), 5+5*i)] = tile.count
+		}
+	}
+
+	owned := scanGrid(img, engine, relicGrid, relics, detectOptions{language: wfm.LangEN})
+	expected := []struct {
+		name  string
+		count string
+	}{
+		{"Axi V8 Relic", "3"},
+		{"Lith A2 Relic", "12"},
+		{"Neo N5 Relic", "1"},
+		// An unreadable count is unknown rather than guessed
+		{"Lith A1 Relic", "1?"},
+	}
+	if len(owned) != len(expected) {
+		t.Fatalf("expected %d relics, got %v", len(expected), owned)
+	}
+	for i, e := range expected {
+		if name := localizedName(owned[i].Item, wfm.LangEN); name != e.name || owned[i].countText() != e.count {
+			t.Errorf("relic %d: expected %s x%s, got %s x%s", i, e.name, e.count, name, owned[i].countText())
+		}
+	}
+	// Empty tiles aren't read past their name
+	if reads := engine.reads; reads > 2*len(layout) {
+		t.Errorf("expected at most %d lines read, got %d", 2*len(layout), reads)
+	}
+}
+
+// gridCaptures are screenshots of item grids at 1920x1080, next to a text
+// file listing the tiles in reading order as "name<TAB>count" in English.
+var gridCaptures = []struct {
+	name      string
+	grid      itemGrid
+	imagePath string
+	items     func(wfm.Language) []wfm.Item
+}{
+	{"relics", relicGrid, "testdata/relics-1.png", getRelics},
+	{"inventory", inventoryGrid, "testdata/inventory-1.png", getRelicItems},
+}
+
+// loadGridExpectations reads the tiles listed next to a grid capture.
+func loadGridExpectations(t *testing.T, imagePath string) []OwnedItem {
+	path := strings.TrimSuffix(imagePath, ".png") + ".txt"
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open expected tiles: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var expected []OwnedItem
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		name, countText, _ := strings.Cut(line, "\t")
+		count, err := strconv.Atoi(countText)
+		if err != nil {
+			t.Fatalf("%s: invalid count in %q", path, line)
+		}
+		expected = append(expected, OwnedItem{
+			Item:  wfm.Item{I18N: map[string]*wfm.ItemI18N{"en": {Name: name}}},
+			Count: count,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("could not read expected tiles: %v", err)
+	}
+	return expected
+}
+
+func TestScanGridCaptures(t *testing.T) {
+	for _, tt := range gridCaptures {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := os.Stat(tt.imagePath); errors.Is(err, fs.ErrNotExist) {
+				t.Skipf("no capture of the %s screen at %s", tt.name, tt.imagePath)
+			}
+			items := tt.items(wfm.LangEN)
+			if len(items) == 0 {
+				t.Skip("item catalog unavailable")
+			}
+			expected := loadGridExpectations(t, tt.imagePath)
+			engine, err := NewOCREngine("", 0, "")
+			if err != nil {
+				t.Fatalf("Error configuring OCR: %v", err)
+			}
+			defer func() { _ = engine.Close() }()
+
+			owned := scanGrid(loadTestImage(t, tt.imagePath), engine, tt.grid, items, detectOptions{language: wfm.LangEN})
+			if len(owned) != len(expected) {
+				t.Fatalf("expected %d tiles, got %d: %v", len(expected), len(owned), owned)
+			}
+			for i, e := range expected {
+				name, expectedName := localizedName(owned[i].Item, wfm.LangEN), localizedName(e.Item, wfm.LangEN)
+				if name != expectedName || owned[i].Count != e.Count {
+					t.Errorf("tile %d: expected %s x%d, got %s x%d", i, expectedName, e.Count, name, owned[i].Count)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	text := func(s string) *string { return &s }
+	tests := []struct {
+		text     *string
+		expected int
+		wantErr  bool
+	}{
+		{text("x3"), 3, false},
+		{text("X 12"), 12, false},
+		{text("7"), 7, false},
+		{text("x"), 0, true},
+		{text("x0"), 0, true},
+		{nil, 1, false},
+	}
+	for _, tt := range tests {
+		name := "<nil>"
+		if tt.text != nil {
+			name = *tt.text
+		}
+		actual, err := parseCount(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCount(%q) error = %v; wantErr %v", name, err, tt.wantErr)
+		}
+		if actual != tt.expected {
+			t.Errorf("parseCount(%q) = %d; want %d", name, actual, tt.expected)
+		}
+	}
+}
+
+func TestItemGrids(t *testing.T) {
+	for name, grid := range map[string]itemGrid{"relics": relicGrid, "inventory": inventoryGrid} {
+		if region := grid.region(); !region.In(image.Rect(0, 0, 1920, 1080)) {
+			t.Errorf("%s: expected the grid within a 1080p screen, got %v", name, region)
+		}
+		if grid.theme.text.Empty() {
+			t.Errorf("%s: expected a theme sample", name)
+		}
+		for i, tile := range grid.tiles() {
+			if grid.theme.region().Overlaps(tile) {
+				t.Errorf("%s tile %d: expected the theme sample %v outside %v", name, i, grid.theme.region(), tile)
+			}
+			for _, box := range []image.Rectangle{grid.nameBox(tile), grid.countBox(tile)} {
+				if !box.In(tile) || box.Empty() {
+					t.Errorf("%s tile %d: expected box %v within %v", name, i, box, tile)
+				}
+			}
+		}
+	}
+}
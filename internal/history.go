@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// historyPath returns path, or the history file of the user data directory
+// when path is empty.
+func historyPath(path string) (string, error) {
+	if path != "" {
+		return expandPath(path)
+	}
+	dir, err := userDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// historyReward is a reward of a reward screen and its value when shown.
+type historyReward struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Price is 0 when the reward had no sell orders.
+	Price  float64 `json:"price"`
+	Ducats int32   `json:"ducats"`
+}
+
+// rewardScreen is a reward screen as kept in the history, one JSON object
+// per line.
+type rewardScreen struct {
+	Time time.Time `json:"time"`
+	// Session is the time wfinfo-go was started, screens of the same run
+	// share it.
+	Session time.Time       `json:"session"`
+	Rewards []historyReward `json:"rewards"`
+	// Picked is the id of the reward picked, when it was recorded.
+	Picked string `json:"picked,omitempty"`
+	// Relic is the relic opened, when every reward only drops from the same
+	// one. Tier is its tier, which every reward of a fissure shares.
+	Relic string `json:"relic,omitempty"`
+	Tier  string `json:"tier,omitempty"`
+}
+
+// newRewardScreen records items, priced by prices, as shown at now. The
+// relic is looked up in drops, which may be nil.
+func newRewardScreen(now, session time.Time, items []wfm.Item, prices []float64, drops map[string][]relicReward) rewardScreen {
+	screen := rewardScreen{Time: now, Session: session, Rewards: make([]historyReward, len(items))}
+	for i, item := range items {
+		screen.Rewards[i] = historyReward{Id: item.Id, Name: localizedName(item, wfm.LangEN), Price: prices[i], Ducats: item.Ducats}
+	}
+	screen.Relic, screen.Tier = rewardRelic(items, drops)
+	return screen
+}
+
+// rewardRelic returns the relic every one of items drops from and its tier,
+// or empty strings when they don't narrow it down to one. Forma drops from
+// every relic and doesn't count.
+func rewardRelic(items []wfm.Item, drops map[string][]relicReward) (relic, tier string) {
+	var relics, tiers map[string]bool
+	for _, item := range items {
+		if slices.Contains(item.Tags, "forma") {
+			continue
+		}
+		name := localizedName(item, wfm.LangEN)
+		itemRelics, itemTiers := map[string]bool{}, map[string]bool{}
+		for relicName, rewards := range drops {
+			if slices.ContainsFunc(rewards, func(r relicReward) bool {
+				// The drop table leaves the Blueprint off warframe parts
+				return r.Item == name || r.Item+" Blueprint" == name
+			}) {
+				itemRelics[relicName] = true
+				itemTiers[strings.Fields(relicName)[0]] = true
+			}
+		}
+		relics, tiers = intersect(relics, itemRelics), intersect(tiers, itemTiers)
+	}
+	if len(relics) == 1 {
+		relic = slices.Collect(maps.Keys(relics))[0]
+	}
+	if len(tiers) == 1 {
+		tier = slices.Collect(maps.Keys(tiers))[0]
+	}
+	return relic, tier
+}
+
+// intersect returns the keys in both a and b, a nil a standing for every key.
+func intersect(a, b map[string]bool) map[string]bool {
+	if a == nil {
+		return b
+	}
+	both := map[string]bool{}
+	for key := range a {
+		if b[key] {
+			both[key] = true
+		}
+	}
+	return both
+}
+
+// picked is the reward picked from s. It returns false when the pick wasn't
+// recorded.
+func (s rewardScreen) picked() (historyReward, bool) {
+	if i := slices.IndexFunc(s.Rewards, func(r historyReward) bool { return r.Id == s.Picked }); s.Picked != "" && i >= 0 {
+		return s.Rewards[i], true
+	}
+	return historyReward{}, false
+}
+
+// best is the most valuable reward of s, the most a screen without a
+// recorded pick earned. It returns false for a screen without rewards.
+func (s rewardScreen) best() (historyReward, bool) {
+	if len(s.Rewards) == 0 {
+		return historyReward{}, false
+	}
+	return slices.MaxFunc(s.Rewards, func(a, b historyReward) int {
+		return cmp.Or(cmp.Compare(a.Price, b.Price), cmp.Compare(a.Ducats, b.Ducats))
+	}), true
+}
+
+// historyPick records the reward picked on the screen shown at Shown. It
+// follows the screen in the history file, as the pick is only known once
+// the screen is written.
+type historyPick struct {
+	Shown  time.Time `json:"pick_of"`
+	Picked string    `json:"picked"`
+}
+
+// historyLine is a line of the history file, a screen or, when PickOf is
+// set, the pick of an earlier one.
+type historyLine struct {
+	rewardScreen
+	PickOf *time.Time `json:"pick_of,omitempty"`
+}
+
+// appendHistory adds screen to the end of the history file at path.
+func appendHistory(path string, screen rewardScreen) error {
+	return appendHistoryLine(path, screen)
+}
+
+// appendHistoryLine adds v as a line of JSON to the end of the history file
+// at path.
+func appendHistoryLine(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadHistory reads the history file at path, oldest screen first, with
+// the picks recorded after them merged in. A pick of a screen missing from
+// the file is dropped. A missing file has no screens.
+func loadHistory(path string) ([]rewardScreen, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	screens := []rewardScreen{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var l historyLine
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if l.PickOf == nil {
+			screens = append(screens, l.rewardScreen)
+			continue
+		}
+		// The screen picked from is usually the last one
+		for i := len(screens) - 1; i >= 0; i-- {
+			if screens[i].Time.Equal(*l.PickOf) {
+				screens[i].Picked = l.Picked
+				break
+			}
+		}
+	}
+	return screens, scanner.Err()
+}
+
+// setHistoryPick records id as the reward picked on the screen shown at
+// shown, adding the pick to the end of the history file at path rather
+// than rewriting the screen.
+func setHistoryPick(path string, shown time.Time, id string) error {
+	return appendHistoryLine(path, historyPick{Shown: shown, Picked: id})
+}
+
+// historyGroup adds up the rewards earned on the screens sharing a key.
+// Plat and Ducats add up the rewards picked, screens without a recorded pick
+// add their best reward to UnpickedPlat and UnpickedDucats instead.
+type historyGroup struct {
+	Key            string
+	Screens        int
+	Picked         int
+	Plat           float64
+	Ducats         int
+	UnpickedPlat   float64
+	UnpickedDucats int
+}
+
+// groupHistory groups screens by key, in the order keys first appear.
+func groupHistory(screens []rewardScreen, key func(rewardScreen) string) []historyGroup {
+	groups := []historyGroup{}
+	index := map[string]int{}
+	for _, screen := range screens {
+		k := key(screen)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, historyGroup{Key: k})
+		}
+		groups[i].Screens++
+		if reward, ok := screen.picked(); ok {
+			groups[i].Picked++
+			groups[i].Plat += reward.Price
+			groups[i].Ducats += int(reward.Ducats)
+		} else if reward, ok := screen.best(); ok {
+			groups[i].UnpickedPlat += reward.Price
+			groups[i].UnpickedDucats += int(reward.Ducats)
+		}
+	}
+	return groups
+}
+
+// printHistory prints the rewards earned on screens per session, per day and
+// per relic tier, with times in loc. What screens without a recorded pick
+// earned isn't known, they're added up apart as if their best reward was
+// picked.
+func printHistory(w io.Writer, screens []rewardScreen, loc *time.Location) error {
+	tables := []struct {
+		title string
+		key   func(rewardScreen) string
+	}{
+		{"SESSION", func(s rewardScreen) string { return s.Session.In(loc).Format("2006-01-02 15:04") }},
+		{"DAY", func(s rewardScreen) string { return s.Time.In(loc).Format("2006-01-02") }},
+		{"RELIC", func(s rewardScreen) string { return cmp.Or(s.Tier, "Unknown") }},
+	}
+	for i, table := range tables {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "%s\tSCREENS\tPICKED\tPLAT\tDUCATS\tUNPICKED PLAT\tUNPICKED DUCATS\n", table.title)
+		for _, group := range groupHistory(screens, table.key) {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%.2fp\t%d\t%.2fp\t%d\n", group.Key, group.Screens, group.Picked, group.Plat, group.Ducats, group.UnpickedPlat, group.UnpickedDucats)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "\nPLAT and DUCATS add up the rewards picked. Screens without a recorded pick count their most valuable reward under UNPICKED, the most they earned.")
+	return err
+}
+
+// HistoryConfig holds the options for RunHistory.
+type HistoryConfig struct {
+	// Path is the history file, defaults to a file in the user data
+	// directory.
+	Path string
+}
+
+// RunHistory prints the platinum and ducats earned from the reward screens
+// in the history. Screens without a recorded pick count their most valuable
+// reward apart, as an upper bound.
+func RunHistory(cfg HistoryConfig) error {
+	path, err := historyPath(cfg.Path)
+	if err != nil {
+		return err
+	}
+	screens, err := loadHistory(path)
+	if err != nil {
+		return err
+	}
+	if len(screens) == 0 {
+		return fmt.Errorf("no reward screens in %s yet", path)
+	}
+	return printHistory(os.Stdout, screens, time.Local)
+}
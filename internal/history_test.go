@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func TestRewardRelic(t *testing.T) {
+	drops := map[string][]relicReward{
+		"Lith A1 Relic": {{Item: "Lex Prime Barrel"}, {Item: "Forma Blueprint"}, {Item: "Volt Prime Neuroptics"}},
+		"Lith B2 Relic": {{Item: "Lex Prime Barrel"}, {Item: "Tekko Prime Gauntlet"}},
+		"Axi A1 Relic":  {{Item: "Tekko Prime Gauntlet"}, {Item: "Forma Blueprint"}},
+	}
+	lex := inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45)
+	tekko := inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15)
+	volt := inventoryItem("volt_prime_neuroptics", "Volt Prime Neuroptics Blueprint", 100)
+	forma := inventoryItem("forma", "Forma Blueprint", 0, "forma")
+	tests := []struct {
+		name  string
+		items []wfm.Item
+		relic string
+		tier  string
+	}{
+		{"one relic", []wfm.Item{lex, volt, forma}, "Lith A1 Relic", "Lith"},
+		{"one tier", []wfm.Item{lex, lex}, "", "Lith"},
+		{"several tiers", []wfm.Item{tekko}, "", ""},
+		{"narrowed to a tier", []wfm.Item{tekko, lex}, "Lith B2 Relic", "Lith"},
+		{"forma only", []wfm.Item{forma}, "", ""},
+		{"no drop table", []wfm.Item{lex}, "", ""},
+	}
+	for _, tt := range tests {
+		table := drops
+		if tt.name == "no drop table" {
+			table = nil
+		}
+		relic, tier := rewardRelic(tt.items, table)
+		if relic != tt.relic || tier != tt.tier {
+			t.Errorf("%s: expected %q %q, got %q %q", tt.name, tt.relic, tt.tier, relic, tier)
+		}
+	}
+}
+
+func TestPickedAndBest(t *testing.T) {
+	rewards := []historyReward{
+		{Id: "forma", Name: "Forma Blueprint"},
+		{Id: "lex_prime_barrel", Name: "Lex Prime Barrel", Price: 5, Ducats: 45},
+		{Id: "volt_prime_neuroptics", Name: "Volt Prime Neuroptics Blueprint", Price: 5, Ducats: 100},
+	}
+	tests := []struct {
+		name   string
+		screen rewardScreen
+		picked string
+		best   string
+	}{
+		{"picked", rewardScreen{Rewards: rewards, Picked: "forma"}, "forma", "volt_prime_neuroptics"},
+		{"not picked", rewardScreen{Rewards: rewards}, "", "volt_prime_neuroptics"},
+		{"pick missing from the rewards", rewardScreen{Rewards: rewards, Picked: "ash_prime_set"}, "", "volt_prime_neuroptics"},
+		{"no rewards", rewardScreen{}, "", ""},
+	}
+	for _, tt := range tests {
+		if reward, ok := tt.screen.picked(); reward.Id != tt.picked || ok != (tt.picked != "") {
+			t.Errorf("%s: expected %q picked, got %q, %v", tt.name, tt.picked, reward.Id, ok)
+		}
+		if reward, ok := tt.screen.best(); reward.Id != tt.best || ok != (tt.best != "") {
+			t.Errorf("%s: expected %q best, got %q, %v", tt.name, tt.best, reward.Id, ok)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "history.jsonl")
+	if screens, err := loadHistory(path); err != nil || len(screens) != 0 {
+		t.Fatalf("expected no screens from a missing file, got %v, %v", screens, err)
+	}
+
+	session := time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)
+	items := []wfm.Item{
+		inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45),
+		inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15),
+	}
+	for i := range 3 {
+		screen := newRewardScreen(session.Add(time.Duration(i)*time.Minute), session, items, []float64{5, 0}, nil)
+		if err := appendHistory(path, screen); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := setHistoryPick(path, session.Add(time.Minute), "tekko_prime_gauntlet"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A pick of a screen missing from the history is dropped
+	if err := setHistoryPick(path, session.Add(time.Hour), "lex_prime_barrel"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := setHistoryPick(path, session, "lex_prime_barrel"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(after, before) {
+		t.Error("expected the pick to be appended without rewriting the history")
+	}
+	// A later pick of the same screen replaces the earlier one
+	if err := setHistoryPick(path, session, "tekko_prime_gauntlet"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	screens, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(screens) != 3 {
+		t.Fatalf("expected 3 screens, got %v", screens)
+	}
+	for i, screen := range screens {
+		picked := ""
+		if i <= 1 {
+			picked = "tekko_prime_gauntlet"
+		}
+		if !screen.Time.Equal(session.Add(time.Duration(i)*time.Minute)) || !screen.Session.Equal(session) || screen.Picked != picked {
+			t.Errorf("screen %d: unexpected %+v", i, screen)
+		}
+		if len(screen.Rewards) != 2 || screen.Rewards[0] != (historyReward{"lex_prime_barrel", "Lex Prime Barrel", 5, 45}) {
+			t.Errorf("screen %d: unexpected rewards %v", i, screen.Rewards)
+		}
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	first := time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	second := time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC)
+	lex := historyReward{Id: "lex_prime_barrel", Price: 5, Ducats: 45}
+	volt := historyReward{Id: "volt_prime_neuroptics", Price: 20, Ducats: 100}
+	screens := []rewardScreen{
+		{Time: first.Add(30 * time.Minute), Session: first, Rewards: []historyReward{lex, volt}, Tier: "Lith"},
+		{Time: first.Add(90 * time.Minute), Session: first, Rewards: []historyReward{lex, volt}, Picked: "lex_prime_barrel", Tier: "Axi"},
+		{Time: second.Add(time.Minute), Session: second, Rewards: []historyReward{lex}},
+	}
+	var out bytes.Buffer
+	if err := printHistory(&out, screens, time.UTC); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "" +
+		"SESSION           SCREENS  PICKED  PLAT   DUCATS  UNPICKED PLAT  UNPICKED DUCATS\n" +
+		"2026-10-18 23:00  2        1       5.00p  45      20.00p         100\n" +
+		"2026-10-19 18:00  1        0       0.00p  0       5.00p          45\n" +
+		"\n" +
+		"DAY         SCREENS  PICKED  PLAT   DUCATS  UNPICKED PLAT  UNPICKED DUCATS\n" +
+		"2026-10-18  1        0       0.00p  0       20.00p         100\n" +
+		"2026-10-19  2        1       5.00p  45      5.00p          45\n" +
+		"\n" +
+		"RELIC    SCREENS  PICKED  PLAT   DUCATS  UNPICKED PLAT  UNPICKED DUCATS\n" +
+		"Lith     1        0       0.00p  0       20.00p         100\n" +
+		"Axi      1        1       5.00p  45      0.00p          0\n" +
+		"Unknown  1        0       0.00p  0       5.00p          45\n" +
+		"\n" +
+		"PLAT and DUCATS add up the rewards picked. Screens without a recorded pick count their most valuable reward under UNPICKED, the most they earned.\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,323 @@
+package internal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anthonynsimon/bild/transform"
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+const (
+	// iconBaseURL is where the icon paths of the item catalog are served.
+	iconBaseURL = "https://warframe.market/static/assets/"
+	// artHeight is the height of the artwork above each reward box, and
+	// artGap the space left below it for names wrapping upwards.
+	artHeight = 180
+	artGap    = 7
+	// iconShrink is the factor artwork and icons are shrunk by before
+	// matching, detail finer than that doesn't help tell parts apart.
+	iconShrink = 3
+	// iconWeight is the share of the icon match in the combined score of a
+	// candidate, the rest is its OCR score relative to the best.
+	iconWeight = 0.5
+	// iconAmbiguity is the percentage of the best OCR score a candidate needs
+	// for its icon to be compared.
+	iconAmbiguity = 80
+)
+
+// iconScales are the sizes tried for an icon, relative to filling the artwork.
+var iconScales = []float64{1, 0.85, 0.7}
+
+// rewardArt is the artwork of the reward card whose name is in box.
+func rewardArt(box image.Rectangle) image.Rectangle {
+	return image.Rect(box.Min.X, box.Min.Y-artGap-artHeight, box.Max.X, box.Min.Y-artGap)
+}
+
+// IconMatcher compares the artwork of reward cards with the item icons of
+// the catalog, downloaded once and cached on disk.
+type IconMatcher struct {
+	client  *http.Client
+	baseURL string
+	dir     string
+	// mu guards icons, by item slug. Each icon is loaded under its own lock
+	// so a download doesn't hold up the others.
+	mu    sync.Mutex
+	icons map[string]*cachedIcon
+}
+
+// cachedIcon is an icon once loaded. Its lock is held while loading, a
+// failed load is tried again on the next use.
+type cachedIcon struct {
+	mu  sync.Mutex
+	img image.Image
+}
+
+// NewIconMatcher creates a matcher caching icons in the user cache directory.
+func NewIconMatcher() (*IconMatcher, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return newIconMatcher(iconBaseURL, filepath.Join(cacheDir, "wfinfo-go", "icons"), &http.Client{Timeout: 30 * time.Second}), nil
+}
+
+func newIconMatcher(baseURL, dir string, client *http.Client) *IconMatcher {
+	return &IconMatcher{client: client, baseURL: baseURL, dir: dir, icons: map[string]*cachedIcon{}}
+}
+
+// itemIcon is the path of the icon of item. The catalog lists it with the
+// English name, which it keeps next to the others, any other language's is
+// the same picture.
+func itemIcon(item wfm.Item) string {
+	if i18n, ok := item.I18N[string(wfm.LangEN)]; ok && i18n.Icon != "" {
+		return i18n.Icon
+	}
+	for _, lang := range slices.Sorted(maps.Keys(item.I18N)) {
+		if i18n := item.I18N[lang]; i18n != nil && i18n.Icon != "" {
+			return i18n.Icon
+		}
+	}
+	return ""
+}
+
+// icon returns the icon of item from memory, the disk cache or the catalog's
+// server, in that order.
+func (m *IconMatcher) icon(item wfm.Item) (image.Image, error) {
+	iconPath := itemIcon(item)
+	if iconPath == "" {
+		return nil, fmt.Errorf("no icon for %s", item.Slug)
+	}
+	m.mu.Lock()
+	cached, ok := m.icons[item.Slug]
+	if !ok {
+		cached = &cachedIcon{}
+		m.icons[item.Slug] = cached
+	}
+	m.mu.Unlock()
+
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	if cached.img != nil {
+		return cached.img, nil
+	}
+
+	cachePath := filepath.Join(m.dir, path.Base(iconPath))
+	file, err := os.Open(cachePath)
+	if os.IsNotExist(err) {
+		if err := m.download(iconPath, cachePath); err != nil {
+			return nil, fmt.Errorf("unable to download icon for %s: %w", item.Slug, err)
+		}
+		file, err = os.Open(cachePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	icon, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid icon for %s: %w", item.Slug, err)
+	}
+	cached.img = icon
+	return icon, nil
+}
+
+func (m *IconMatcher) download(iconPath, cachePath string) error {
+	resp, err := m.client.Get(m.baseURL + iconPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so an interrupted download isn't
+	// mistaken for an icon later
+	tmp, err := os.CreateTemp(m.dir, ".download-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cachePath)
+}
+
+// rerank orders the candidates scoring close to the best OCR match by their
+// OCR score combined with how well their icon matches the artwork at art in
+// img. Candidates are returned unchanged when OCR alone is decisive. Those
+// whose icon is unavailable keep their place and the rest are reranked
+// around them, the errors loading icons are returned with the ranking.
+func (m *IconMatcher) rerank(img image.Image, art image.Rectangle, candidates []matchCandidate, items []wfm.Item, lang wfm.Language) ([]matchCandidate, error) {
+	if len(candidates) < 2 || candidates[0].Score <= 0 {
+		return candidates, nil
+	}
+	best := candidates[0].Score
+	ambiguous := 0
+	for ambiguous < len(candidates) && candidates[ambiguous].Score*100 >= best*iconAmbiguity {
+		ambiguous++
+	}
+	if ambiguous < 2 {
+		return candidates, nil
+	}
+
+	artwork := shrinkGray(transform.Crop(img, art), iconShrink)
+	ranked := slices.Clone(candidates)
+	// The places of the candidates with an icon, and those candidates
+	var places []int
+	var compared []matchCandidate
+	combined := make(map[string]float64, ambiguous)
+	var errs []error
+	for i := range ranked[:ambiguous] {
+		item, err := getItemFromName(ranked[i].Name, items, lang)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		icon, err := m.icon(item)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ranked[i].Icon = iconScore(artwork, icon)
+		combined[ranked[i].Name] = (1-iconWeight)*float64(ranked[i].Score)/float64(best) + iconWeight*ranked[i].Icon
+		places = append(places, i)
+		compared = append(compared, ranked[i])
+	}
+	slices.SortStableFunc(compared, func(a, b matchCandidate) int {
+		return cmp.Compare(combined[b.Name], combined[a.Name])
+	})
+	for i, place := range places {
+		ranked[place] = compared[i]
+	}
+	return ranked, errors.Join(errs...)
+}
+
+// grayImage is a grayscale image as floats, with a mask of the pixels that
+// count.
+type grayImage struct {
+	w, h int
+	pix  []float64
+	mask []bool
+}
+
+// shrinkGray averages img down by factor into a grayscale image. Pixels more
+// than half transparent are masked out.
+func shrinkGray(img image.Image, factor int) grayImage {
+	bounds := img.Bounds()
+	g := grayImage{w: bounds.Dx() / factor, h: bounds.Dy() / factor}
+	g.pix = make([]float64, g.w*g.h)
+	g.mask = make([]bool, g.w*g.h)
+	for y := range g.h {
+		for x := range g.w {
+			var luma, alpha float64
+			for sy := range factor {
+				for sx := range factor {
+					r, gr, b, a := img.At(bounds.Min.X+x*factor+sx, bounds.Min.Y+y*factor+sy).RGBA()
+					luma += 0.299*float64(r) + 0.587*float64(gr) + 0.114*float64(b)
+					alpha += float64(a)
+				}
+			}
+			n := float64(factor * factor)
+			g.pix[y*g.w+x] = luma / n / 0xffff
+			g.mask[y*g.w+x] = alpha/n >= 0x8000
+		}
+	}
+	return g
+}
+
+// iconScore is the best normalized cross-correlation of icon over artwork,
+// across iconScales and positions, between 0 and 1. The icon's transparent
+// background is ignored, the card behind the artwork varies.
+func iconScore(artwork grayImage, icon image.Image) float64 {
+	// Icons have a margin around the item, fit the item itself
+	bounds := opaqueBounds(icon)
+	if bounds.Empty() || artwork.w == 0 || artwork.h == 0 {
+		return 0
+	}
+	best := 0.0
+	fit := min(float64(artwork.w*iconShrink)/float64(bounds.Dx()), float64(artwork.h*iconShrink)/float64(bounds.Dy()))
+	for _, scale := range iconScales {
+		w := int(float64(bounds.Dx()) * fit * scale)
+		h := int(float64(bounds.Dy()) * fit * scale)
+		if w < iconShrink || h < iconShrink {
+			continue
+		}
+		resized := transform.Resize(transform.Crop(icon, bounds), w, h, transform.Linear)
+		template := shrinkGray(resized, iconShrink)
+		for y := 0; y+template.h <= artwork.h; y++ {
+			for x := 0; x+template.w <= artwork.w; x++ {
+				best = max(best, maskedCorrelation(artwork, template, x, y))
+			}
+		}
+	}
+	return best
+}
+
+// maskedCorrelation is the normalized cross-correlation of the unmasked
+// pixels of template with artwork at x, y.
+func maskedCorrelation(artwork, template grayImage, x, y int) float64 {
+	var n, sumA, sumT, sumAA, sumTT, sumAT float64
+	for ty := range template.h {
+		row := (y+ty)*artwork.w + x
+		for tx := range template.w {
+			i := ty*template.w + tx
+			if !template.mask[i] {
+				continue
+			}
+			a, t := artwork.pix[row+tx], template.pix[i]
+			n++
+			sumA += a
+			sumT += t
+			sumAA += a * a
+			sumTT += t * t
+			sumAT += a * t
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	cov := sumAT - sumA*sumT/n
+	varA := sumAA - sumA*sumA/n
+	varT := sumTT - sumT*sumT/n
+	if varA <= 0 || varT <= 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varT)
+}
+
+// opaqueBounds is the bounding box of the pixels of img that are more than
+// half opaque.
+func opaqueBounds(img image.Image) image.Rectangle {
+	bounds := img.Bounds()
+	opaque := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a >= 0x8000 {
+				opaque = opaque.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return opaque
+}
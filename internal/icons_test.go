@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthonynsimon/bild/transform"
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// testIcon draws a transparent icon of size with shape opaque.
+func testIcon(size int, shape func(x, y int) (color.NRGBA, bool)) *image.NRGBA {
+	icon := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			if c, ok := shape(x, y); ok {
+				icon.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return icon
+}
+
+var (
+	// ringIcon is a light ring with a dark center, like a round part.
+	ringIcon = testIcon(120, func(x, y int) (color.NRGBA, bool) {
+		dx, dy := x-60, y-60
+		d := dx*dx + dy*dy
+		switch {
+		case d < 20*20:
+			return color.NRGBA{40, 40, 60, 255}, true
+		case d < 55*55:
+			return color.NRGBA{220, 210, 180, 255}, true
+		}
+		return color.NRGBA{}, false
+	})
+	// barsIcon is a square of horizontal stripes, like a blade.
+	barsIcon = testIcon(120, func(x, y int) (color.NRGBA, bool) {
+		if x < 10 || x >= 110 || y < 10 || y >= 110 {
+			return color.NRGBA{}, false
+		}
+		if y/15%2 == 0 {
+			return color.NRGBA{200, 200, 200, 255}, true
+		}
+		return color.NRGBA{60, 50, 40, 255}, true
+	})
+)
+
+// testScreen returns a 1080p screen with icon drawn in the artwork of the
+// first reward card, over a gradient background.
+func testScreen(icon image.Image) *image.RGBA {
+	// Cards show the item a little smaller than the artwork
+	icon = transform.Resize(icon, 150, 150, transform.Linear)
+	img := image.NewRGBA(image.Rect(0, 0, 1920, 1080))
+	for y := range 1080 {
+		for x := range 1920 {
+			img.SetRGBA(x, y, color.RGBA{uint8(x / 8), uint8(y / 5), 90, 255})
+		}
+	}
+	art := rewardArt(rewardBoxes[0])
+	at := image.Pt(art.Min.X+(art.Dx()-icon.Bounds().Dx())/2, art.Min.Y+(art.Dy()-icon.Bounds().Dy())/2)
+	draw.Draw(img, icon.Bounds().Add(at), icon, image.Point{}, draw.Over)
+	return img
+}
+
+func encodeIcon(t *testing.T, icon image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, icon); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// iconServer serves icons by path and counts the requests made.
+func iconServer(t *testing.T, icons map[string]image.Image) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	files := map[string][]byte{}
+	for path, icon := range icons {
+		files["/"+path] = encodeIcon(t, icon)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func iconItem(name, icon string) wfm.Item {
+	return wfm.Item{Slug: name, I18N: map[string]*wfm.ItemI18N{"en": {Name: name, Icon: icon}}}
+}
+
+func TestIconScore(t *testing.T) {
+	artwork := shrinkGray(testScreen(ringIcon).SubImage(rewardArt(rewardBoxes[0])), iconShrink)
+	ring := iconScore(artwork, ringIcon)
+	bars := iconScore(artwork, barsIcon)
+	if ring < 0.9 {
+		t.Errorf("expected the drawn icon to score at least 0.9, got %.2f", ring)
+	}
+	if bars >= ring {
+		t.Errorf("expected a different icon to score below %.2f, got %.2f", ring, bars)
+	}
+	if empty := iconScore(artwork, image.NewNRGBA(image.Rect(0, 0, 10, 10))); empty != 0 {
+		t.Errorf("expected a transparent icon to score 0, got %.2f", empty)
+	}
+}
+
+func TestIconMatcherCache(t *testing.T) {
+	server, requests := iconServer(t, map[string]image.Image{"items/images/en/ring.png": ringIcon})
+	dir := t.TempDir()
+	item := iconItem("Ring", "items/images/en/ring.png")
+
+	for range 2 {
+		m := newIconMatcher(server.URL+"/", dir, server.Client())
+		for range 2 {
+			icon, err := m.icon(item)
+			if err != nil {
+				t.Fatalf("icon: %v", err)
+			}
+			if icon.Bounds() != ringIcon.Bounds() {
+				t.Errorf("expected icon bounds %v, got %v", ringIcon.Bounds(), icon.Bounds())
+			}
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected the icon to be downloaded once, got %d requests", n)
+	}
+
+	m := newIconMatcher(server.URL+"/", dir, server.Client())
+	if _, err := m.icon(iconItem("Missing", "items/images/en/missing.png")); err == nil {
+		t.Error("expected an error for a missing icon")
+	}
+	if _, err := m.icon(iconItem("None", "")); err == nil {
+		t.Error("expected an error for an item without an icon")
+	}
+
+	// An item listed without its English name uses another language's icon
+	localized := wfm.Item{Slug: "ring", I18N: map[string]*wfm.ItemI18N{"de": {Name: "Ring", Icon: "items/images/en/ring.png"}}}
+	if _, err := m.icon(localized); err != nil {
+		t.Errorf("expected the localized icon, got %v", err)
+	}
+}
+
+func TestIconMatcherConcurrentDownloads(t *testing.T) {
+	files := map[string][]byte{"/ring.png": encodeIcon(t, ringIcon), "/bars.png": encodeIcon(t, barsIcon)}
+	barsServed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The ring icon is only served once the bars icon was, which never
+		// happens if one download holds up the other
+		if r.URL.Path == "/ring.png" {
+			select {
+			case <-barsServed:
+			case <-time.After(5 * time.Second):
+				http.Error(w, "bars icon never requested", http.StatusGatewayTimeout)
+				return
+			}
+		}
+		_, _ = w.Write(files[r.URL.Path])
+		if r.URL.Path == "/bars.png" {
+			close(barsServed)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	m := newIconMatcher(server.URL+"/", t.TempDir(), server.Client())
+	ring := make(chan error)
+	go func() {
+		_, err := m.icon(iconItem("Ring", "ring.png"))
+		ring <- err
+	}()
+	// Let the ring download start first
+	time.Sleep(50 * time.Millisecond)
+	if _, err := m.icon(iconItem("Bars", "bars.png")); err != nil {
+		t.Errorf("bars icon: %v", err)
+	}
+	if err := <-ring; err != nil {
+		t.Errorf("ring icon: %v", err)
+	}
+}
+
+func TestRerank(t *testing.T) {
+	server, requests := iconServer(t, map[string]image.Image{"ring.png": ringIcon, "bars.png": barsIcon})
+	items := []wfm.Item{
+		iconItem("Bars Prime Systems", "bars.png"),
+		iconItem("Ring Prime Neuroptics", "ring.png"),
+		iconItem("Forma Blueprint", "missing.png"),
+	}
+	img := testScreen(ringIcon)
+
+	tests := []struct {
+		name       string
+		candidates []matchCandidate
+		expected   []string
+		requests   int32
+		wantErr    bool
+	}{
+		{
+			name:       "ambiguous",
+			candidates: []matchCandidate{{Name: "Bars Prime Systems", Score: 30}, {Name: "Ring Prime Neuroptics", Score: 27}, {Name: "Forma Blueprint", Score: 4}},
+			expected:   []string{"Ring Prime Neuroptics", "Bars Prime Systems", "Forma Blueprint"},
+			requests:   2,
+		},
+		{
+			// A missing icon keeps its candidate in place, the others are
+			// still compared
+			name:       "missing icon",
+			candidates: []matchCandidate{{Name: "Bars Prime Systems", Score: 30}, {Name: "Forma Blueprint", Score: 29}, {Name: "Ring Prime Neuroptics", Score: 27}},
+			expected:   []string{"Ring Prime Neuroptics", "Forma Blueprint", "Bars Prime Systems"},
+			requests:   3,
+			wantErr:    true,
+		},
+		{
+			name:       "decisive",
+			candidates: []matchCandidate{{Name: "Bars Prime Systems", Score: 30}, {Name: "Ring Prime Neuroptics", Score: 12}},
+			expected:   []string{"Bars Prime Systems", "Ring Prime Neuroptics"},
+		},
+		{
+			name:       "single",
+			candidates: []matchCandidate{{Name: "Bars Prime Systems", Score: 30}},
+			expected:   []string{"Bars Prime Systems"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			m := newIconMatcher(server.URL+"/", t.TempDir(), server.Client())
+			ranked, err := m.rerank(img, rewardArt(rewardBoxes[0]), tt.candidates, items, wfm.LangEN)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rerank error = %v; wantErr %v", err, tt.wantErr)
+			}
+			names := make([]string, 0, len(ranked))
+			for _, candidate := range ranked {
+				names = append(names, candidate.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+			if n := requests.Load(); n != tt.requests {
+				t.Errorf("expected %d icon requests, got %d", tt.requests, n)
+			}
+		})
+	}
+}
+
+func TestDetectionRegionIcons(t *testing.T) {
+	region := detectionRegion(WithIconMatcher(&IconMatcher{}))
+	for _, rect := range rewardBoxes {
+		if art := rewardArt(rect); !art.In(region) {
+			t.Errorf("expected artwork %v within %v", art, region)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// inventoryGrid is the inventory with its search filtering the prime parts,
+// the count owned is in the top left corner of each tile and the theme is
+// sampled from the title above the tiles. TestScanGridCaptures reads the
+// screen at testdata/inventory-1.png, with the parts expected in
+// testdata/inventory-1.txt.
+var inventoryGrid = itemGrid{
+	origin:  image.Pt(100, 260),
+	spacing: image.Pt(175, 190),
+	cols:    7,
+	rows:    4,
+	name:    image.Rect(5, 125, 170, 185),
+	count:   image.Rect(5, 5, 60, 35),
+	theme:   themeSample{text: image.Rect(104, 190, 108, 220)},
+}
+
+// inventoryRow is a part owned, its price and what it trades for at Baro.
+type inventoryRow struct {
+	OwnedItem
+	// Price is the price of one, 0 when it has none.
+	Price float64
+}
+
+// Plat is the price of every part of the row.
+func (r inventoryRow) Plat() float64 {
+	return r.Price * float64(r.Count)
+}
+
+// Ducats is the ducats of every part of the row.
+func (r inventoryRow) Ducats() int {
+	return int(r.Item.Ducats) * r.Count
+}
+
+// priceInventory adds up the counts of every part of owned and prices them,
+// most valuable first. price returns the price of one part.
+func priceInventory(owned []OwnedItem, price func(wfm.Item) (float64, error)) []inventoryRow {
+	rows := []inventoryRow{}
+	index := map[string]int{}
+	for _, part := range owned {
+		if i, ok := index[part.Item.Id]; ok {
+			rows[i].Count += part.Count
+			rows[i].CountUnknown = rows[i].CountUnknown || part.CountUnknown
+			continue
+		}
+		index[part.Item.Id] = len(rows)
+		rows = append(rows, inventoryRow{OwnedItem: part})
+	}
+	for i := range rows {
+		if slices.Contains(rows[i].Item.Tags, "forma") {
+			// Forma isn't traded
+			continue
+		}
+		p, err := price(rows[i].Item)
+		if err != nil {
+			log.Printf("Error: Unable to fetch price information for %v, %v\n", rows[i].Item.Id, err)
+		}
+		rows[i].Price = p
+	}
+	slices.SortStableFunc(rows, func(a, b inventoryRow) int {
+		return cmp.Or(cmp.Compare(b.Plat(), a.Plat()), cmp.Compare(b.Ducats(), a.Ducats()))
+	})
+	return rows
+}
+
+// printInventory prints rows as a table with the totals of the inventory,
+// naming items by names. Ducats per platinum tells parts better traded to
+// Baro from parts better sold.
+func printInventory(w io.Writer, rows []inventoryRow, names func(wfm.Item) string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ITEM\tCOUNT\tPRICE\tDUCATS\tPLAT TOTAL\tDUCAT TOTAL\tDUCATS/PLAT")
+	var count, ducats int
+	var plat float64
+	for _, row := range rows {
+		price, ratio := "-", "-"
+		if row.Price > 0 {
+			price = fmt.Sprintf("%.2fp", row.Price)
+			ratio = fmt.Sprintf("%.1f", float64(row.Item.Ducats)/row.Price)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.2fp\t%d\t%s\n", names(row.Item), row.countText(), price, row.Item.Ducats, row.Plat(), row.Ducats(), ratio)
+		count += row.Count
+		plat += row.Plat()
+		ducats += row.Ducats()
+	}
+	_, _ = fmt.Fprintf(tw, "TOTAL\t%d\t\t\t%.2fp\t%d\n", count, plat, ducats)
+	return tw.Flush()
+}
+
+// RunInventory captures the inventory once, reads the prime parts on screen
+// and prints them with their prices and ducats. With RecordInventory the
+// counts read are recorded in the owned parts file, for the sets of rewards,
+// parts whose count couldn't be read keep their previous count.
+func RunInventory(cfg Config) error {
+	lang := gameLanguage(cfg.GameLanguage)
+	parts := getRelicItems(lang)
+	if len(parts) == 0 {
+		return fmt.Errorf("item catalog unavailable")
+	}
+	owned, err := captureGrid(cfg, inventoryGrid, "inventory", parts)
+	if err != nil {
+		return err
+	}
+	if len(owned) == 0 {
+		return fmt.Errorf("no prime parts found, is the inventory open?")
+	}
+
+	wfmClient := wfm.NewClient()
+	rows := priceInventory(owned, func(item wfm.Item) (float64, error) {
+		return itemPrice(wfmClient, item)
+	})
+	if err := printInventory(os.Stdout, rows, displayNames(lang, cfg.DisplayLanguage)); err != nil {
+		return err
+	}
+	if !cfg.RecordInventory {
+		log.Println("counts not recorded, check them and rerun with -record to record them")
+		return nil
+	}
+	ownedPath, err := ownedPartsPath(cfg.OwnedPartsPath)
+	if err != nil {
+		return err
+	}
+	known := make([]inventoryRow, 0, len(rows))
+	for _, row := range rows {
+		if row.CountUnknown {
+			log.Printf("count of %s not recorded, it couldn't be read", localizedName(row.Item, lang))
+			continue
+		}
+		known = append(known, row)
+	}
+	if err := recordOwned(ownedPath, known); err != nil {
+		return err
+	}
+	log.Printf("recorded %d parts in %s", len(known), ownedPath)
+	return nil
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func inventoryItem(id, name string, ducats int32, tags ...string) wfm.Item {
+	return wfm.Item{Id: id, Ducats: ducats, Tags: tags, I18N: map[string]*wfm.ItemI18N{"en": {Name: name}}}
+}
+
+func TestPriceInventory(t *testing.T) {
+	tekko := inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15, "prime")
+	volt := inventoryItem("volt_prime_neuroptics", "Volt Prime Neuroptics Blueprint", 100, "prime")
+	lex := inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45, "prime")
+	forma := inventoryItem("forma", "Forma Blueprint", 0, "forma")
+	owned := []OwnedItem{{Item: tekko, Count: 4}, {Item: volt, Count: 1}, {Item: forma, Count: 2}, {Item: tekko, Count: 2, CountUnknown: true}, {Item: lex, Count: 3}}
+	prices := map[string]float64{"tekko_prime_gauntlet": 3, "volt_prime_neuroptics": 20}
+	fetched := map[string]int{}
+	price := func(item wfm.Item) (float64, error) {
+		fetched[item.Id]++
+		if p, ok := prices[item.Id]; ok {
+			return p, nil
+		}
+		return 0, fmt.Errorf("no sell orders")
+	}
+
+	rows := priceInventory(owned, price)
+	expected := []struct {
+		id     string
+		count  string
+		plat   float64
+		ducats int
+	}{
+		{"volt_prime_neuroptics", "1", 20, 100},
+		// A tile whose count couldn't be read leaves the total unknown
+		{"tekko_prime_gauntlet", "6?", 18, 90},
+		{"lex_prime_barrel", "3", 0, 135},
+		{"forma", "2", 0, 0},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("expected %d rows, got %v", len(expected), rows)
+	}
+	for i, e := range expected {
+		row := rows[i]
+		if row.Item.Id != e.id || row.countText() != e.count || row.Plat() != e.plat || row.Ducats() != e.ducats {
+			t.Errorf("row %d: expected %s x%s, %.2fp, %d ducats, got %s x%s, %.2fp, %d ducats", i, e.id, e.count, e.plat, e.ducats, row.Item.Id, row.countText(), row.Plat(), row.Ducats())
+		}
+	}
+	if fetched["forma"] != 0 || fetched["tekko_prime_gauntlet"] != 1 {
+		t.Errorf("expected every traded part to be priced once, got %v", fetched)
+	}
+}
+
+func TestPrintInventory(t *testing.T) {
+	rows := []inventoryRow{
+		{OwnedItem: OwnedItem{Item: inventoryItem("volt_prime_neuroptics", "Volt Prime Neuroptics Blueprint", 100), Count: 1}, Price: 20},
+		{OwnedItem: OwnedItem{Item: inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45), Count: 3, CountUnknown: true}},
+	}
+	var out bytes.Buffer
+	if err := printInventory(&out, rows, func(item wfm.Item) string { return localizedName(item, wfm.LangEN) }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "" +
+		"ITEM                             COUNT  PRICE   DUCATS  PLAT TOTAL  DUCAT TOTAL  DUCATS/PLAT\n" +
+		"Volt Prime Neuroptics Blueprint  1      20.00p  100     20.00p      100          5.0\n" +
+		"Lex Prime Barrel                 3?     -       45      0.00p       135          -\n" +
+		"TOTAL                            4                      20.00p      235\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -1,30 +1,55 @@
 package internal
 
 import (
+	"cmp"
+	"fmt"
 	"slices"
 
 	"github.com/simon-wg/wfinfo-go/internal/wfm"
 )
 
-func getRelicItems() []wfm.Item {
-	client := wfm.NewClient()
+// getRelicItems returns the items relics can reward, with their names in
+// lang next to English.
+func getRelicItems(lang wfm.Language) []wfm.Item {
+	client := wfm.NewClient(wfm.WithLanguage(lang))
 	items, err := client.FetchItems()
 	if err != nil {
 		return nil
 	}
-	primes := filterPrimeItems(items)
-	relicItems := append(primes, wfm.Item{
+	return append(filterPrimeItems(items), formaItem())
+}
+
+// formaNames is what the game calls the Forma Blueprint in each language.
+// The market doesn't list Forma, so these don't come from its catalog.
+var formaNames = map[wfm.Language]string{
+	wfm.LangEN:     "Forma Blueprint",
+	wfm.LangDE:     "Forma-Blaupause",
+	wfm.LangES:     "Plano de Forma",
+	wfm.LangFR:     "Schéma de Forma",
+	wfm.LangIT:     "Progetto della Forma",
+	wfm.LangKO:     "포르마 설계도",
+	wfm.LangPL:     "Forma (Schemat)",
+	wfm.LangPT:     "Diagrama de Forma",
+	wfm.LangRU:     "Форма (Чертёж)",
+	wfm.LangUK:     "Форма (Креслення)",
+	wfm.LangZHSimp: "Forma 蓝图",
+	wfm.LangZHTrad: "Forma 藍圖",
+}
+
+// formaItem is the Forma Blueprint every relic can reward, named in every
+// language.
+func formaItem() wfm.Item {
+	forma := wfm.Item{
 		Id:      "forma",
 		Slug:    "forma",
 		GameRef: "forma",
 		Tags:    []string{"forma"},
-		I18N: map[string]*wfm.ItemI18N{
-			"en": {
-				Name: "Forma Blueprint",
-			},
-		},
-	})
-	return relicItems
+		I18N:    map[string]*wfm.ItemI18N{},
+	}
+	for lang, name := range formaNames {
+		forma.I18N[string(lang)] = &wfm.ItemI18N{Name: name}
+	}
+	return forma
 }
 
 func filterPrimeItems(items []wfm.Item) []wfm.Item {
@@ -47,27 +72,63 @@ func filterPrimeItems(items []wfm.Item) []wfm.Item {
 	return primeItems
 }
 
-func getItemNames(items []wfm.Item) []string {
+// localizedName returns the name of item in lang, or its English name when
+// the item has no translation.
+func localizedName(item wfm.Item, lang wfm.Language) string {
+	if i18n, ok := item.I18N[string(lang)]; ok && i18n.Name != "" {
+		return i18n.Name
+	}
+	if i18n, ok := item.I18N[string(wfm.LangEN)]; ok {
+		return i18n.Name
+	}
+	return ""
+}
+
+// gameLanguage defaults an unset game language to English.
+func gameLanguage(lang wfm.Language) wfm.Language {
+	if lang == "" {
+		return wfm.LangEN
+	}
+	return lang
+}
+
+// displayNames returns a function naming items in display, or in game when
+// display is unset. Detected items only carry names in the game's language
+// and English, names in other languages come from their own catalog.
+func displayNames(game, display wfm.Language) func(wfm.Item) string {
+	if display == "" || display == game || display == wfm.LangEN {
+		return func(item wfm.Item) string {
+			return localizedName(item, cmp.Or(display, game))
+		}
+	}
+	catalog := map[string]string{}
+	for _, item := range getRelicItems(display) {
+		catalog[item.Id] = localizedName(item, display)
+	}
+	return func(item wfm.Item) string {
+		if name, ok := catalog[item.Id]; ok {
+			return name
+		}
+		return localizedName(item, display)
+	}
+}
+
+func getItemNames(items []wfm.Item, lang wfm.Language) []string {
 	names := []string{}
 	for _, item := range items {
-		names = append(names, item.I18N["en"].Name)
+		names = append(names, localizedName(item, lang))
 	}
 	return names
 }
 
-func findBestItem(itemName string, relicItems []wfm.Item, relicItemNames []string) wfm.Item {
-	bestName := smithWaterman(itemName, relicItemNames)
-	item := getItemFromName(bestName, relicItems)
-	return item
-}
-
-func getItemFromName(name string, items []wfm.Item) wfm.Item {
+// getItemFromName returns the item of items named name in lang.
+func getItemFromName(name string, items []wfm.Item, lang wfm.Language) (wfm.Item, error) {
 	for _, item := range items {
-		if item.I18N["en"].Name == name {
-			return item
+		if localizedName(item, lang) == name {
+			return item, nil
 		}
 	}
-	panic("no item found")
+	return wfm.Item{}, fmt.Errorf("no item named %q", name)
 }
 
 const (
@@ -76,23 +137,26 @@ const (
 	gapPenalty    = -1
 )
 
-func smithWaterman(s string, ss []string) string {
-	bestScore := -1
-	bestMatch := ""
+// matchCandidate is an item name and its alignment score against OCR text.
+type matchCandidate struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	// Icon is how well the item's icon matches the card artwork, from 0 to
+	// 1, when icons were compared.
+	Icon float64 `json:"icon_score,omitempty"`
+}
 
-	// Convert query to runes for unicode safety
+// topMatches returns the n names in ss scoring best against s, best first.
+func topMatches(s string, ss []string, n int) []matchCandidate {
 	queryRunes := []rune(s)
-
+	candidates := make([]matchCandidate, 0, len(ss))
 	for _, candidate := range ss {
-		candidateRunes := []rune(candidate)
-		score := calculateScore(queryRunes, candidateRunes)
-		if score > bestScore {
-			bestScore = score
-			bestMatch = candidate
-		}
+		candidates = append(candidates, matchCandidate{Name: candidate, Score: calculateScore(queryRunes, []rune(candidate))})
 	}
-
-	return bestMatch
+	slices.SortStableFunc(candidates, func(a, b matchCandidate) int {
+		return b.Score - a.Score
+	})
+	return candidates[:min(n, len(candidates))]
 }
 
 func calculateScore(s1, s2 []rune) int {
@@ -67,7 +67,7 @@ func TestGetItemNames(t *testing.T) {
 		{I18N: map[string]*wfm.ItemI18N{"en": {Name: "Item 2"}}},
 	}
 
-	names := getItemNames(items)
+	names := getItemNames(items, wfm.LangEN)
 	expected := []string{"Item 1", "Item 2"}
 
 	if !slices.Equal(names, expected) {
@@ -75,7 +75,55 @@ func TestGetItemNames(t *testing.T) {
 	}
 }
 
-func TestSmithWaterman(t *testing.T) {
+func TestLocalizedName(t *testing.T) {
+	item := wfm.Item{I18N: map[string]*wfm.ItemI18N{
+		"en": {Name: "Ash Prime Blueprint"},
+		"de": {Name: "Ash Prime Blaupause"},
+	}}
+	tests := []struct {
+		lang     wfm.Language
+		expected string
+	}{
+		{wfm.LangEN, "Ash Prime Blueprint"},
+		{wfm.LangDE, "Ash Prime Blaupause"},
+		// Untranslated items keep their English name
+		{wfm.LangFR, "Ash Prime Blueprint"},
+	}
+	for _, tt := range tests {
+		if actual := localizedName(item, tt.lang); actual != tt.expected {
+			t.Errorf("localizedName(%s) = %q; want %q", tt.lang, actual, tt.expected)
+		}
+	}
+
+	items := []wfm.Item{formaItem(), item}
+	names := getItemNames(items, wfm.LangDE)
+	best, err := getItemFromName(topMatches("Ash Prlme Blaupase", names, 1)[0].Name, items, wfm.LangDE)
+	if err != nil || localizedName(best, wfm.LangDE) != "Ash Prime Blaupause" {
+		t.Errorf("expected Ash Prime Blaupause, got %v, %v", localizedName(best, wfm.LangDE), err)
+	}
+	best, err = getItemFromName(topMatches("Forma-Blaupase", names, 1)[0].Name, items, wfm.LangDE)
+	if err != nil || best.Id != "forma" {
+		t.Errorf("expected forma, got %v, %v", best.Id, err)
+	}
+	// Names are only looked up in the language they're in
+	if _, err := getItemFromName("Ash Prime Blaupause", items, wfm.LangEN); err == nil {
+		t.Error("expected an error for a name of another language")
+	}
+}
+
+func TestFormaItem(t *testing.T) {
+	forma := formaItem()
+	for _, lang := range wfm.Languages {
+		if name, ok := forma.I18N[string(lang)]; !ok || name.Name == "" {
+			t.Errorf("expected a Forma name in %s", lang)
+		}
+	}
+	if name := localizedName(forma, wfm.LangFR); name != "Schéma de Forma" {
+		t.Errorf("expected Schéma de Forma, got %q", name)
+	}
+}
+
+func TestBestMatch(t *testing.T) {
 	tests := []struct {
 		query      string
 		candidates []string
@@ -100,10 +148,29 @@ func TestSmithWaterman(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.query, func(t *testing.T) {
-			actual := smithWaterman(tt.query, tt.candidates)
+			actual := topMatches(tt.query, tt.candidates, 1)[0].Name
 			if actual != tt.expected {
-				t.Errorf("smithWaterman(%s, %v) = %s; want %s", tt.query, tt.candidates, actual, tt.expected)
+				t.Errorf("topMatches(%s, %v) = %s; want %s", tt.query, tt.candidates, actual, tt.expected)
 			}
 		})
 	}
 }
+
+func TestTopMatches(t *testing.T) {
+	candidates := []string{"Mag Prime Blueprint", "Excalibur Prime Chassis", "Excalibur Prime Blueprint"}
+
+	actual := topMatches("Excalbur Prime Bluepnt", candidates, 2)
+	if len(actual) != 2 {
+		t.Fatalf("expected 2 candidates, got %v", actual)
+	}
+	if actual[0].Name != "Excalibur Prime Blueprint" {
+		t.Errorf("expected best candidate Excalibur Prime Blueprint, got %v", actual)
+	}
+	if actual[0].Score < actual[1].Score {
+		t.Errorf("expected candidates ordered by score, got %v", actual)
+	}
+
+	if actual := topMatches("Mag", candidates, 10); len(actual) != len(candidates) {
+		t.Errorf("expected every candidate when n is larger, got %v", actual)
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"slices"
+	"strings"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// OCREngine reads the text of a binarized line of an item name. Engines are
+// used from several goroutines at once.
+type OCREngine interface {
+	ReadLine(line *image.RGBA) (string, error)
+	// Close releases the engine once no line is being read.
+	Close() error
+}
+
+// wordUpdater is implemented by engines tuned to the item catalog, so they
+// follow its updates while running.
+type wordUpdater interface {
+	// updateWords tunes the engine to the item names, doing nothing when
+	// their words are those it knows.
+	updateWords(names []string) error
+}
+
+// ocrConfig is what an engine is created for.
+type ocrConfig struct {
+	// workers is how many lines are read in parallel, 0 picks a default.
+	workers int
+	lang    wfm.Language
+	// vocabulary names words, the text the engine reads when it isn't item
+	// names.
+	vocabulary string
+	words      []string
+}
+
+// OCROption configures NewOCREngine.
+type OCROption func(*ocrConfig)
+
+// WithVocabulary tunes the engine to words, such as relic names, instead of
+// the item names of reward screens. name keeps what the engine learns about
+// them apart from other vocabularies.
+func WithVocabulary(name string, words []string) OCROption {
+	return func(c *ocrConfig) {
+		c.vocabulary = name
+		c.words = words
+	}
+}
+
+// ocrEngineFactory creates an engine for cfg.
+type ocrEngineFactory func(cfg ocrConfig) (OCREngine, error)
+
+var ocrEngineFactories = map[string]ocrEngineFactory{}
+
+// registerOCREngine makes an engine available to NewOCREngine. Engines call
+// it from init, those needing cgo only exist in cgo builds.
+func registerOCREngine(name string, factory ocrEngineFactory) {
+	ocrEngineFactories[name] = factory
+}
+
+// DefaultOCREngine is Tesseract when the build includes it, the glyph
+// recognizer otherwise.
+func DefaultOCREngine() string {
+	if _, ok := ocrEngineFactories["tesseract"]; ok {
+		return "tesseract"
+	}
+	return "glyph"
+}
+
+// NewOCREngine creates the engine called name, the default when empty, for
+// a game running in lang, English when empty. It reads up to workers lines
+// in parallel, 0 picks a default.
+func NewOCREngine(name string, workers int, lang wfm.Language, opts ...OCROption) (OCREngine, error) {
+	if name == "" {
+		name = DefaultOCREngine()
+	}
+	factory, ok := ocrEngineFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown OCR engine %q (available: %s)", name, strings.Join(OCREngines(), ", "))
+	}
+	if workers < 0 {
+		return nil, fmt.Errorf("invalid OCR worker count %d", workers)
+	}
+	if lang == "" {
+		lang = wfm.LangEN
+	}
+	cfg := ocrConfig{workers: workers, lang: lang}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return factory(cfg)
+}
+
+// OCREngines lists the names of the OCR engines in this build.
+func OCREngines() []string {
+	names := make([]string, 0, len(ocrEngineFactories))
+	for name := range ocrEngineFactories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
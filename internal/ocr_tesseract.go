@@ -0,0 +1,248 @@
+//go:build cgo
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/otiai10/gosseract/v2"
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func init() {
+	registerOCREngine("tesseract", func(cfg ocrConfig) (OCREngine, error) {
+		return newTesseractEngine(cfg)
+	})
+}
+
+// TesseractEngine holds Tesseract clients so reward boxes can be read in
+// parallel. A client is only ever used by one goroutine at a time.
+type TesseractEngine struct {
+	clients chan *gosseract.Client
+	all     []*gosseract.Client
+	// lang and vocabulary are what the clients read, words the item words
+	// they were configured with, empty until the catalog is available.
+	lang       wfm.Language
+	vocabulary string
+	mu         sync.Mutex
+	words      string
+}
+
+// defaultOCRWorkers is one client per reward box, or per CPU if fewer.
+func defaultOCRWorkers() int {
+	return max(1, min(runtime.NumCPU(), len(rewardBoxes)))
+}
+
+// tesseractLanguages maps the game's languages to the Tesseract language
+// data reading them.
+var tesseractLanguages = map[wfm.Language]string{
+	wfm.LangEN:     "eng",
+	wfm.LangDE:     "deu",
+	wfm.LangES:     "spa",
+	wfm.LangFR:     "fra",
+	wfm.LangIT:     "ita",
+	wfm.LangKO:     "kor",
+	wfm.LangPL:     "pol",
+	wfm.LangPT:     "por",
+	wfm.LangRU:     "rus",
+	wfm.LangUK:     "ukr",
+	wfm.LangZHSimp: "chi_sim",
+	wfm.LangZHTrad: "chi_tra",
+}
+
+// NewTesseractEngine creates an engine with size clients reading item names
+// in lang, size 0 picks a default. The clients know the words and characters
+// of the item catalog when it is available.
+func NewTesseractEngine(size int, lang wfm.Language) (*TesseractEngine, error) {
+	return newTesseractEngine(ocrConfig{workers: size, lang: lang})
+}
+
+// newTesseractEngine creates an engine for cfg. Its clients know the words
+// and characters of cfg's vocabulary, or of the item catalog.
+func newTesseractEngine(cfg ocrConfig) (*TesseractEngine, error) {
+	size, lang := cfg.workers, cfg.lang
+	if size < 0 {
+		return nil, fmt.Errorf("invalid OCR worker count %d", size)
+	}
+	if size == 0 {
+		size = defaultOCRWorkers()
+	}
+	tessLang, ok := tesseractLanguages[lang]
+	if !ok {
+		return nil, fmt.Errorf("no Tesseract language data for %q", lang)
+	}
+	// English ships with Tesseract, other languages are separate packages
+	if available, err := gosseract.GetAvailableLanguages(); err == nil && len(available) > 0 && !slices.Contains(available, tessLang) {
+		return nil, fmt.Errorf("tesseract language data %q for %s is not installed", tessLang, lang)
+	}
+
+	names := cfg.words
+	if cfg.vocabulary == "" {
+		names = getItemNames(getRelicItems(lang), lang)
+	}
+	configPath, err := itemTesseractConfig(names, lang, cfg.vocabulary)
+	if err != nil {
+		log.Printf("Reading without item words, unable to write Tesseract config: %v", err)
+	}
+	whitelist := itemCharacters(names)
+	if len(names) == 0 && lang == wfm.LangEN && cfg.vocabulary == "" {
+		whitelist = ocrWhitelist
+	}
+
+	engine := &TesseractEngine{clients: make(chan *gosseract.Client, size), lang: lang, vocabulary: cfg.vocabulary}
+	if configPath != "" {
+		engine.words = strings.Join(itemWords(names), "\n")
+	}
+	for range size {
+		client, err := newOCRClient(tessLang, whitelist, configPath)
+		if err != nil {
+			_ = engine.Close()
+			return nil, err
+		}
+		engine.all = append(engine.all, client)
+		engine.clients <- client
+	}
+	return engine, nil
+}
+
+// ocrWhitelist holds every character that appears in English item names, for
+// when the item catalog is unavailable.
+const ocrWhitelist = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ& \n"
+
+// newOCRClient creates a client reading one line of item name text at a time
+// with the Tesseract language tessLang. Only characters in whitelist are
+// read, any when it's empty. configPath, if set, is a Tesseract config file
+// such as the one from itemTesseractConfig.
+func newOCRClient(tessLang, whitelist, configPath string) (*gosseract.Client, error) {
+	ocrClient := gosseract.NewClient()
+	if err := ocrClient.SetLanguage(tessLang); err != nil {
+		_ = ocrClient.Close()
+		return nil, fmt.Errorf("failed to configure OCR: %w", err)
+	}
+	if whitelist != "" {
+		if err := ocrClient.SetWhitelist(whitelist); err != nil {
+			_ = ocrClient.Close()
+			return nil, fmt.Errorf("failed to configure OCR: %w", err)
+		}
+	}
+	// Set as a variable, gosseract reapplies those after initializing
+	if err := ocrClient.SetVariable("tessedit_pageseg_mode", strconv.Itoa(int(gosseract.PSM_SINGLE_LINE))); err != nil {
+		_ = ocrClient.Close()
+		return nil, fmt.Errorf("failed to configure OCR: %w", err)
+	}
+	if configPath != "" {
+		if err := ocrClient.SetConfigFile(configPath); err != nil {
+			_ = ocrClient.Close()
+			return nil, fmt.Errorf("failed to configure OCR: %w", err)
+		}
+	}
+	return ocrClient, nil
+}
+
+// updateWords tunes the clients to names, the item catalog, when its words
+// changed since they were configured. The user words file is rewritten and
+// the clients reload it before their next line. Engines tuned to another
+// vocabulary keep their words.
+func (e *TesseractEngine) updateWords(names []string) error {
+	if e.vocabulary != "" || len(names) == 0 {
+		return nil
+	}
+	words := strings.Join(itemWords(names), "\n")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if words == e.words {
+		return nil
+	}
+	configPath, err := itemTesseractConfig(names, e.lang, "")
+	if err != nil {
+		return err
+	}
+	whitelist := itemCharacters(names)
+
+	// Take every client so none is reading while it's reconfigured
+	clients := make([]*gosseract.Client, 0, len(e.all))
+	for range e.all {
+		clients = append(clients, e.acquire())
+	}
+	var errs []error
+	for _, client := range clients {
+		if err := client.SetWhitelist(whitelist); err != nil {
+			errs = append(errs, err)
+		}
+		// Setting the config again reinitializes the client, loading the
+		// new words
+		if err := client.SetConfigFile(configPath); err != nil {
+			errs = append(errs, err)
+		}
+		e.release(client)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to configure OCR: %w", err)
+	}
+	e.words = words
+	return nil
+}
+
+// Size returns the number of clients in the engine.
+func (e *TesseractEngine) Size() int {
+	return len(e.all)
+}
+
+// ReadLine reads line with the next free client.
+func (e *TesseractEngine) ReadLine(line *image.RGBA) (string, error) {
+	client := e.acquire()
+	defer e.release(client)
+	if err := client.SetImageFromBytes(encodePGM(line)); err != nil {
+		return "", err
+	}
+	return client.Text()
+}
+
+// acquire waits for a free client. It must be handed back with release.
+func (e *TesseractEngine) acquire() *gosseract.Client {
+	return <-e.clients
+}
+
+func (e *TesseractEngine) release(client *gosseract.Client) {
+	e.clients <- client
+}
+
+// Close waits for every client to be released and closes them. The engine
+// can't be used afterwards.
+func (e *TesseractEngine) Close() error {
+	var errs []error
+	for range e.all {
+		if err := e.acquire().Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	e.all = nil
+	return errors.Join(errs...)
+}
+
+// encodePGM encodes img as a binary PGM for Tesseract. Leptonica reads it
+// straight into a Pix, skipping the compression and decompression a PNG
+// round trip costs on every box.
+func encodePGM(img *image.RGBA) []byte {
+	bounds := img.Bounds()
+	header := fmt.Sprintf("P5\n%d %d\n255\n", bounds.Dx(), bounds.Dy())
+	data := make([]byte, len(header), len(header)+bounds.Dx()*bounds.Dy())
+	copy(data, header)
+	for y := range bounds.Dy() {
+		row := img.Pix[y*img.Stride : y*img.Stride+bounds.Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			// ITU-R BT.601 luma, binarized boxes are already gray
+			luma := (299*uint32(row[i]) + 587*uint32(row[i+1]) + 114*uint32(row[i+2]) + 500) / 1000
+			data = append(data, uint8(luma))
+		}
+	}
+	return data
+}
@@ -0,0 +1,190 @@
+//go:build cgo
+
+package internal
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthonynsimon/bild/transform"
+	"github.com/otiai10/gosseract/v2"
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func TestTesseractEngine(t *testing.T) {
+	engine, err := NewTesseractEngine(2, wfm.LangEN)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if engine.Size() != 2 {
+		t.Errorf("expected 2 clients, got %d", engine.Size())
+	}
+
+	first := engine.acquire()
+	second := engine.acquire()
+	if first == second {
+		t.Error("expected distinct clients")
+	}
+
+	// Close waits for clients in use to be released
+	closed := make(chan error)
+	go func() { closed <- engine.Close() }()
+	engine.release(first)
+	select {
+	case <-closed:
+		t.Fatal("expected Close to wait for the client still in use")
+	case <-time.After(20 * time.Millisecond):
+	}
+	engine.release(second)
+	if err := <-closed; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewTesseractEngineSize(t *testing.T) {
+	engine, err := NewTesseractEngine(0, wfm.LangEN)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = engine.Close() }()
+	if engine.Size() != defaultOCRWorkers() {
+		t.Errorf("expected default size %d, got %d", defaultOCRWorkers(), engine.Size())
+	}
+
+	if _, err := NewTesseractEngine(-1, wfm.LangEN); err == nil {
+		t.Error("expected error for negative size")
+	}
+	if _, err := NewTesseractEngine(1, "xx"); err == nil {
+		t.Error("expected error for unknown language")
+	}
+}
+
+func TestEncodePGM(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.SetRGBA(0, 0, color.RGBA{A: 255})
+	img.SetRGBA(1, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	img.SetRGBA(2, 0, color.RGBA{R: 255, A: 255})
+	img.SetRGBA(0, 1, color.RGBA{R: 128, G: 128, B: 128, A: 255})
+
+	// A sub-image has a stride wider than its rows
+	sub := image.NewRGBA(image.Rect(0, 0, 5, 2))
+	for y := range 2 {
+		for x := range 3 {
+			sub.SetRGBA(x+1, y, img.RGBAAt(x, y))
+		}
+	}
+
+	expected := append([]byte("P5\n3 2\n255\n"), 0, 255, 76, 128, 0, 0)
+	for name, input := range map[string]*image.RGBA{"image": img, "sub-image": sub.SubImage(image.Rect(1, 0, 4, 2)).(*image.RGBA)} {
+		if actual := encodePGM(input); !bytes.Equal(actual, expected) {
+			t.Errorf("%s: expected %v, but got %v", name, expected, actual)
+		}
+	}
+}
+
+// BenchmarkOCRInput measures handing a binarized reward box to Tesseract and
+// reading it, through the old PNG round trip and the PGM path.
+func BenchmarkOCRInput(b *testing.B) {
+	engine, err := NewTesseractEngine(1, wfm.LangEN)
+	if err != nil {
+		b.Fatalf("Error configuring OCR: %v", err)
+	}
+	defer func() { _ = engine.Close() }()
+	client := engine.acquire()
+	defer engine.release(client)
+	img := loadTestImage(b, "testdata/harrier-1.png")
+	theme := detectTheme(img, rewardThemeSample)
+	isolated := binarize(transform.Crop(img, rewardBoxes[0]), theme, BinarizeRGB)
+
+	encoders := []struct {
+		name   string
+		encode func(*image.RGBA) ([]byte, error)
+	}{
+		{"png", func(img *image.RGBA) ([]byte, error) {
+			buf := new(bytes.Buffer)
+			err := png.Encode(buf, img)
+			return buf.Bytes(), err
+		}},
+		{"pgm", func(img *image.RGBA) ([]byte, error) {
+			return encodePGM(img), nil
+		}},
+	}
+	for _, encoder := range encoders {
+		b.Run(encoder.name+"/encode", func(b *testing.B) {
+			for b.Loop() {
+				if _, err := encoder.encode(isolated); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(encoder.name+"/ocr", func(b *testing.B) {
+			for b.Loop() {
+				data, err := encoder.encode(isolated)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := client.SetImageFromBytes(data); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := client.Text(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTesseractEngineUpdateWords(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	engine, err := newTesseractEngine(ocrConfig{workers: 2, lang: wfm.LangEN})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = engine.Close() }()
+
+	// A new item adds its words to the file the clients load
+	names := []string{"Forma Blueprint", "Zyxtar Prime Blueprint"}
+	if err := engine.updateWords(names); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	configPath, err := itemTesseractConfig(names, wfm.LangEN, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	words, err := os.ReadFile(filepath.Join(filepath.Dir(configPath), userWordsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(strings.Fields(string(words)), "Zyxtar") {
+		t.Errorf("expected the new word in the user words, got %q", words)
+	}
+	for i, client := range engine.all {
+		if client.ConfigFilePath != configPath {
+			t.Errorf("client %d: expected config %q, got %q", i, configPath, client.ConfigFilePath)
+		}
+		if whitelist := client.Variables[gosseract.TESSEDIT_CHAR_WHITELIST]; !strings.Contains(whitelist, "Z") {
+			t.Errorf("client %d: expected the new characters in the whitelist, got %q", i, whitelist)
+		}
+	}
+
+	// Unchanged words leave the clients alone
+	for _, client := range engine.all {
+		client.ConfigFilePath = ""
+	}
+	if err := engine.updateWords(names); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, client := range engine.all {
+		if client.ConfigFilePath != "" {
+			t.Errorf("client %d: expected no reload, got %q", i, client.ConfigFilePath)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func TestNewOCREngine(t *testing.T) {
+	if !slices.Contains(OCREngines(), DefaultOCREngine()) {
+		t.Errorf("expected the default engine %q among %v", DefaultOCREngine(), OCREngines())
+	}
+
+	engine, err := NewOCREngine("glyph", 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := engine.(*GlyphEngine); !ok {
+		t.Errorf("expected a glyph engine, got %T", engine)
+	}
+	if err := engine.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := NewOCREngine("unknown", 0, ""); err == nil {
+		t.Error("expected error for unknown engine")
+	}
+	if _, err := NewOCREngine("glyph", -1, ""); err == nil {
+		t.Error("expected error for negative worker count")
+	}
+	if _, err := NewOCREngine("glyph", 0, wfm.LangRU); err == nil {
+		t.Error("expected error for a language the glyph engine can't read")
+	}
+	if _, err := NewOCREngine("glyph", 0, "", WithVocabulary("relics", []string{"Lith A1 Relic"})); err == nil {
+		t.Error("expected error for a vocabulary the glyph engine has no templates for")
+	}
+	if _, err := NewOCREngine("glyph", 0, "", WithVocabulary("parts", []string{"Prime Chassis"})); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+const (
+	userWordsFile   = "items.user-words"
+	tesseractConfig = "wfinfo.config"
+)
+
+// itemWords returns the distinct words of names, sorted.
+func itemWords(names []string) []string {
+	words := []string{}
+	for _, name := range names {
+		words = append(words, strings.Fields(name)...)
+	}
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+// itemCharacters returns every character of names once, sorted, as a
+// whitelist for reading them.
+func itemCharacters(names []string) string {
+	chars := []rune{}
+	for _, name := range names {
+		chars = append(chars, []rune(name)...)
+	}
+	slices.Sort(chars)
+	return string(slices.Compact(chars))
+}
+
+// writeTesseractConfig writes a user words file with the words of names,
+// and a Tesseract config file loading it, to dir. Files are only rewritten
+// when the catalog's words changed. It returns the path of the config file.
+func writeTesseractConfig(dir string, names []string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	wordsPath := filepath.Join(dir, userWordsFile)
+	configPath := filepath.Join(dir, tesseractConfig)
+
+	words := []byte(strings.Join(itemWords(names), "\n") + "\n")
+	config := fmt.Appendf(nil, "user_words_file %s\n", wordsPath)
+	for path, data := range map[string][]byte{wordsPath: words, configPath: config} {
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+			continue
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return "", err
+		}
+	}
+	return configPath, nil
+}
+
+// itemTesseractConfig writes the Tesseract config for the item names of a
+// language, or the words of another vocabulary, to the user cache directory.
+func itemTesseractConfig(names []string, lang wfm.Language, vocabulary string) (string, error) {
+	if len(names) == 0 {
+		return "", fmt.Errorf("item catalog unavailable")
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := string(lang)
+	if vocabulary != "" {
+		dir += "-" + vocabulary
+	}
+	return writeTesseractConfig(filepath.Join(cacheDir, "wfinfo-go", "tesseract", dir), names)
+}
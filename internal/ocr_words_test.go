@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestItemWords(t *testing.T) {
+	names := []string{"Mag Prime Blueprint", "Mag Prime Systems Blueprint", "Forma Blueprint", "Dual Kamas Prime  Blade"}
+	expected := []string{"Blade", "Blueprint", "Dual", "Forma", "Kamas", "Mag", "Prime", "Systems"}
+	if actual := itemWords(names); !slices.Equal(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestItemCharacters(t *testing.T) {
+	names := []string{"Mag Prime", "Маг Прайм", "Forma"}
+	expected := " FMPaegimorМПагймр"
+	if actual := itemCharacters(names); actual != expected {
+		t.Errorf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestWriteTesseractConfig(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tesseract")
+	configPath, err := writeTesseractConfig(dir, []string{"Mag Prime Blueprint", "Forma Blueprint"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wordsPath := filepath.Join(dir, userWordsFile)
+	config, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(config) != "user_words_file "+wordsPath+"\n" {
+		t.Errorf("unexpected config %q", config)
+	}
+	words, err := os.ReadFile(wordsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(words) != "Blueprint\nForma\nMag\nPrime\n" {
+		t.Errorf("unexpected words %q", words)
+	}
+
+	// An unchanged catalog leaves the files alone
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(wordsPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTesseractConfig(dir, []string{"Forma Blueprint", "Mag Prime Blueprint"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := os.Stat(wordsPath); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("expected words file to be left alone, got %v", info.ModTime())
+	}
+
+	if _, err := writeTesseractConfig(dir, []string{"Forma Blueprint", "Nova Prime Blueprint"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	words, err = os.ReadFile(wordsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(words) != "Blueprint\nForma\nNova\nPrime\n" {
+		t.Errorf("expected words to be regenerated, got %q", words)
+	}
+}
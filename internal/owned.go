@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// userDataDir is where state kept between runs is stored,
+// $XDG_DATA_HOME/wfinfo-go or ~/.local/share/wfinfo-go.
+func userDataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "wfinfo-go"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "wfinfo-go"), nil
+}
+
+// ownedPartsPath returns path, or the owned parts file of the user data
+// directory when path is empty.
+func ownedPartsPath(path string) (string, error) {
+	if path != "" {
+		return expandPath(path)
+	}
+	dir, err := userDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "owned-parts.json"), nil
+}
+
+// ownedParts counts the parts owned by item id. It's kept in a JSON file
+// which the inventory and owned commands and picked rewards update, and
+// which sets and the ducats command read.
+type ownedParts map[string]int
+
+// loadOwnedParts reads the owned parts file at path, a missing file owns
+// nothing.
+func loadOwnedParts(path string) (ownedParts, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ownedParts{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	owned := ownedParts{}
+	if err := json.Unmarshal(data, &owned); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return owned, nil
+}
+
+// save writes o to path, replacing it only once fully written.
+func (o ownedParts) save(path string) error {
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data to path through a temporary file, so readers
+// never see it partly written.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// set sets the count of the part id, forgetting parts no longer owned.
+func (o ownedParts) set(id string, count int) {
+	if count <= 0 {
+		delete(o, id)
+		return
+	}
+	o[id] = count
+}
+
+// updateOwned applies update to the owned parts file at path.
+func updateOwned(path string, update func(ownedParts)) error {
+	owned, err := loadOwnedParts(path)
+	if err != nil {
+		return err
+	}
+	update(owned)
+	return owned.save(path)
+}
+
+// recordOwned sets the counts of the parts of rows in the owned parts file
+// at path. Parts off screen keep their previous count.
+func recordOwned(path string, rows []inventoryRow) error {
+	return updateOwned(path, func(owned ownedParts) {
+		for _, row := range rows {
+			owned.set(row.Item.Id, row.Count)
+		}
+	})
+}
+
+// ownedItems returns the parts of owned found in parts, the catalog, in the
+// order of the catalog.
+func ownedItems(owned ownedParts, parts []wfm.Item) []OwnedItem {
+	items := []OwnedItem{}
+	for _, part := range parts {
+		if count := owned[part.Id]; count > 0 {
+			items = append(items, OwnedItem{Item: part, Count: count})
+		}
+	}
+	return items
+}
+
+// parsePick returns the reward picked when input is its number among
+// rewards, counting from one.
+func parsePick(input string, rewards []wfm.Item) (wfm.Item, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || n < 1 || n > len(rewards) {
+		return wfm.Item{}, fmt.Errorf("expected the number of a reward from 1 to %d, got %q", len(rewards), strings.TrimSpace(input))
+	}
+	return rewards[n-1], nil
+}
+
+// pickPrompt asks for the number of the reward picked among rewards, named
+// by names.
+func pickPrompt(rewards []wfm.Item, names func(wfm.Item) string) string {
+	choices := make([]string, len(rewards))
+	for i, reward := range rewards {
+		choices[i] = fmt.Sprintf("%d %s", i+1, names(reward))
+	}
+	// Ex. Picked? 1 Tekko Prime Gauntlet, 2 Forma Blueprint
+	return "Picked? " + strings.Join(choices, ", ")
+}
+
+// recordPick adds the reward picked, input being its number, to the owned
+// parts file at path. It returns the reward and the count now owned.
+func recordPick(path, input string, rewards []wfm.Item) (wfm.Item, int, error) {
+	reward, err := parsePick(input, rewards)
+	if err != nil {
+		return wfm.Item{}, 0, err
+	}
+	var count int
+	err = updateOwned(path, func(owned ownedParts) {
+		count = owned[reward.Id] + 1
+		owned.set(reward.Id, count)
+	})
+	return reward, count, err
+}
+
+// printOwned prints the parts of owned, naming them by names and sorted by
+// name.
+func printOwned(w io.Writer, owned []OwnedItem, names func(wfm.Item) string) error {
+	owned = slices.Clone(owned)
+	slices.SortStableFunc(owned, func(a, b OwnedItem) int {
+		return cmp.Compare(names(a.Item), names(b.Item))
+	})
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ITEM\tCOUNT")
+	count := 0
+	for _, part := range owned {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\n", names(part.Item), part.Count)
+		count += part.Count
+	}
+	_, _ = fmt.Fprintf(tw, "TOTAL\t%d\n", count)
+	return tw.Flush()
+}
+
+// OwnedConfig holds the options of the owned commands.
+type OwnedConfig struct {
+	// Path is the owned parts file, defaults to a file in the user data
+	// directory.
+	Path string
+	// GameLanguage is the language part names are given in, defaults to
+	// English.
+	GameLanguage wfm.Language
+	// DisplayLanguage is the language results are printed in, defaults to
+	// GameLanguage.
+	DisplayLanguage wfm.Language
+}
+
+// ownedCatalog returns the owned parts file and the catalog of parts.
+func ownedCatalog(cfg OwnedConfig) (string, []wfm.Item, error) {
+	path, err := ownedPartsPath(cfg.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	parts := getRelicItems(gameLanguage(cfg.GameLanguage))
+	if len(parts) == 0 {
+		return "", nil, fmt.Errorf("item catalog unavailable")
+	}
+	return path, parts, nil
+}
+
+// ListOwned prints the parts owned.
+func ListOwned(cfg OwnedConfig) error {
+	path, parts, err := ownedCatalog(cfg)
+	if err != nil {
+		return err
+	}
+	owned, err := loadOwnedParts(path)
+	if err != nil {
+		return err
+	}
+	lang := gameLanguage(cfg.GameLanguage)
+	return printOwned(os.Stdout, ownedItems(owned, parts), displayNames(lang, cfg.DisplayLanguage))
+}
+
+// EditOwned changes the count of the part closest to name, setting it to
+// count when set is true and adding count, which may be negative, to it
+// otherwise.
+func EditOwned(cfg OwnedConfig, name string, count int, set bool) error {
+	path, parts, err := ownedCatalog(cfg)
+	if err != nil {
+		return err
+	}
+	lang := gameLanguage(cfg.GameLanguage)
+	part, err := matchPart(name, parts, lang)
+	if err != nil {
+		return err
+	}
+	var total int
+	err = updateOwned(path, func(owned ownedParts) {
+		total = count
+		if !set {
+			total += owned[part.Id]
+		}
+		owned.set(part.Id, total)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s x%d\n", displayNames(lang, cfg.DisplayLanguage)(part), max(total, 0))
+	return nil
+}
+
+// ImportOwned sets the counts of the parts of a CSV file, rows of name and
+// count as read by the ducats command.
+func ImportOwned(cfg OwnedConfig, csvPath string) error {
+	path, parts, err := ownedCatalog(cfg)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	imported, err := readOwnedParts(file, parts, gameLanguage(cfg.GameLanguage))
+	if err != nil {
+		return fmt.Errorf("%s: %w", csvPath, err)
+	}
+	counts := map[string]int{}
+	for _, part := range imported {
+		counts[part.Item.Id] += part.Count
+	}
+	err = updateOwned(path, func(owned ownedParts) {
+		for id, count := range counts {
+			owned.set(id, count)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d parts into %s\n", len(imported), path)
+	return nil
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func TestOwnedParts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "owned-parts.json")
+	owned, err := loadOwnedParts(path)
+	if err != nil || len(owned) != 0 {
+		t.Fatalf("expected no parts from a missing file, got %v, %v", owned, err)
+	}
+
+	tekko := inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15)
+	lex := inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45)
+	volt := inventoryItem("volt_prime_neuroptics", "Volt Prime Neuroptics Blueprint", 100)
+	if err := recordOwned(path, []inventoryRow{{OwnedItem: OwnedItem{Item: tekko, Count: 4}}, {OwnedItem: OwnedItem{Item: lex, Count: 2}}, {OwnedItem: OwnedItem{Item: volt, Count: 1}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := recordOwned(path, []inventoryRow{{OwnedItem: OwnedItem{Item: tekko, Count: 1}}, {OwnedItem: OwnedItem{Item: volt, Count: 0}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	owned, err = loadOwnedParts(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := ownedParts{"tekko_prime_gauntlet": 1, "lex_prime_barrel": 2}
+	if len(owned) != len(expected) || owned["tekko_prime_gauntlet"] != 1 || owned["lex_prime_barrel"] != 2 {
+		t.Errorf("expected %v, got %v", expected, owned)
+	}
+
+	items := ownedItems(owned, []wfm.Item{volt, lex, tekko})
+	if len(items) != 2 || items[0].Item.Id != "lex_prime_barrel" || items[1].Count != 1 {
+		t.Errorf("expected the owned parts in catalog order, got %v", items)
+	}
+}
+
+func TestRecordPick(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owned-parts.json")
+	rewards := []wfm.Item{
+		inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15),
+		inventoryItem("forma", "Forma Blueprint", 0),
+	}
+	names := func(item wfm.Item) string { return localizedName(item, wfm.LangEN) }
+	if prompt := pickPrompt(rewards, names); prompt != "Picked? 1 Tekko Prime Gauntlet, 2 Forma Blueprint" {
+		t.Errorf("unexpected prompt %q", prompt)
+	}
+
+	for _, input := range []string{"", "0", "3", "tekko"} {
+		if _, _, err := recordPick(path, input, rewards); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+	for i := 1; i <= 2; i++ {
+		reward, count, err := recordPick(path, " 1\n", rewards)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if reward.Id != "tekko_prime_gauntlet" || count != i {
+			t.Errorf("expected %d Tekko Prime Gauntlet, got %d %s", i, count, reward.Id)
+		}
+	}
+}
+
+func TestPrintOwned(t *testing.T) {
+	owned := []OwnedItem{
+		{Item: inventoryItem("tekko_prime_gauntlet", "Tekko Prime Gauntlet", 15), Count: 4},
+		{Item: inventoryItem("lex_prime_barrel", "Lex Prime Barrel", 45), Count: 2},
+	}
+	var out bytes.Buffer
+	if err := printOwned(&out, owned, func(item wfm.Item) string { return localizedName(item, wfm.LangEN) }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "" +
+		"ITEM                  COUNT\n" +
+		"Lex Prime Barrel      2\n" +
+		"Tekko Prime Gauntlet  4\n" +
+		"TOTAL                 6\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/anthonynsimon/bild/transform"
+)
+
+// PreprocessStep is one stage of the cleanup run on a binarized reward box
+// before OCR.
+type PreprocessStep struct {
+	name  string
+	apply func(*image.RGBA) *image.RGBA
+}
+
+func (s PreprocessStep) String() string {
+	return s.name
+}
+
+const (
+	defaultUpscale = 3
+	maxUpscale     = 8
+	// maxSkew is the largest rotation in degrees deskew corrects.
+	maxSkew = 5.0
+	// skewStep is the resolution in degrees of the deskew search.
+	skewStep = 0.5
+)
+
+// preprocessSteps builds the named steps, arg is the text after the colon
+// in the pipeline spec, if any.
+var preprocessSteps = map[string]func(arg string) (func(*image.RGBA) *image.RGBA, error){
+	"upscale": func(arg string) (func(*image.RGBA) *image.RGBA, error) {
+		factor := defaultUpscale
+		if arg != "" {
+			var err error
+			if factor, err = strconv.Atoi(arg); err != nil || factor < 1 || factor > maxUpscale {
+				return nil, fmt.Errorf("invalid upscale factor %q, expected 1 to %d", arg, maxUpscale)
+			}
+		}
+		return func(img *image.RGBA) *image.RGBA { return upscale(img, factor) }, nil
+	},
+	"denoise": noArg(denoise),
+	"dilate":  noArg(dilate),
+	"erode":   noArg(erode),
+	"deskew":  noArg(deskew),
+}
+
+func noArg(step func(*image.RGBA) *image.RGBA) func(string) (func(*image.RGBA) *image.RGBA, error) {
+	return func(arg string) (func(*image.RGBA) *image.RGBA, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+		return step, nil
+	}
+}
+
+// ParsePipeline parses a comma separated list of preprocessing steps, run in
+// order, such as "upscale:3,denoise,deskew". The steps are upscale[:N],
+// denoise, dilate, erode and deskew.
+func ParsePipeline(spec string) ([]PreprocessStep, error) {
+	steps := []PreprocessStep{}
+	if strings.TrimSpace(spec) == "" {
+		return steps, nil
+	}
+	for field := range strings.SplitSeq(spec, ",") {
+		field = strings.TrimSpace(field)
+		name, arg, _ := strings.Cut(field, ":")
+		build, ok := preprocessSteps[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown preprocessing step %q (available: upscale[:N], denoise, dilate, erode, deskew)", name)
+		}
+		apply, err := build(arg)
+		if err != nil {
+			return nil, fmt.Errorf("preprocessing step %s: %w", name, err)
+		}
+		steps = append(steps, PreprocessStep{name: field, apply: apply})
+	}
+	return steps, nil
+}
+
+// preprocess runs img through steps in order.
+func preprocess(img *image.RGBA, steps []PreprocessStep) *image.RGBA {
+	for _, step := range steps {
+		img = step.apply(img)
+	}
+	return img
+}
+
+// isInk reports whether the pixel at offset i of a binarized image is text.
+func isInk(pix []uint8, i int) bool {
+	return pix[i] < 128
+}
+
+func setInk(pix []uint8, i int, ink bool) {
+	v := uint8(255)
+	if ink {
+		v = 0
+	}
+	pix[i], pix[i+1], pix[i+2], pix[i+3] = v, v, v, 255
+}
+
+// upscale enlarges img by factor, smoothing the staircase edges of the
+// binarized text before thresholding it again.
+func upscale(img *image.RGBA, factor int) *image.RGBA {
+	if factor <= 1 {
+		return img
+	}
+	bounds := img.Bounds()
+	scaled := transform.Resize(img, bounds.Dx()*factor, bounds.Dy()*factor, transform.Linear)
+	for i := 0; i < len(scaled.Pix); i += 4 {
+		setInk(scaled.Pix, i, isInk(scaled.Pix, i))
+	}
+	return scaled
+}
+
+// morph sets every pixel to ink when keep accepts the number of ink pixels
+// in its 3x3 neighbourhood. Pixels outside the image count as background.
+func morph(img *image.RGBA, keep func(ink int) bool) *image.RGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dest := image.NewRGBA(bounds)
+	for y := range h {
+		for x := range w {
+			ink := 0
+			for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+					if isInk(img.Pix, ny*img.Stride+nx*4) {
+						ink++
+					}
+				}
+			}
+			setInk(dest.Pix, y*dest.Stride+x*4, keep(ink))
+		}
+	}
+	return dest
+}
+
+// denoise is a 3x3 majority filter, dropping specks and filling pinholes.
+func denoise(img *image.RGBA) *image.RGBA {
+	return morph(img, func(ink int) bool { return ink >= 5 })
+}
+
+// dilate thickens text by a pixel, joining strokes broken by thresholding.
+func dilate(img *image.RGBA) *image.RGBA {
+	return morph(img, func(ink int) bool { return ink > 0 })
+}
+
+// erode thins text by a pixel, separating letters that bled together.
+func erode(img *image.RGBA) *image.RGBA {
+	return morph(img, func(ink int) bool { return ink == 9 })
+}
+
+// deskew rotates img by the angle within maxSkew that lines the text up with
+// the rows best, judged by how sharply the ink is concentrated in few rows.
+func deskew(img *image.RGBA) *image.RGBA {
+	angle := skewAngle(img)
+	if angle == 0 {
+		return img
+	}
+	return rotate(img, angle)
+}
+
+// skewAngle returns the rotation in degrees that straightens the text of img.
+func skewAngle(img *image.RGBA) float64 {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	cx, cy := float64(w)/2, float64(h)/2
+	type point struct{ x, y float64 }
+	ink := []point{}
+	for y := range h {
+		for x := range w {
+			if isInk(img.Pix, y*img.Stride+x*4) {
+				ink = append(ink, point{float64(x) - cx, float64(y) - cy})
+			}
+		}
+	}
+	if len(ink) == 0 {
+		return 0
+	}
+
+	best, bestScore := 0.0, -1.0
+	rows := make(map[int]int, h)
+	for angle := -maxSkew; angle <= maxSkew; angle += skewStep {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		clear(rows)
+		for _, p := range ink {
+			rows[int(math.Round(p.x*sin+p.y*cos))]++
+		}
+		score := 0.0
+		for _, n := range rows {
+			score += float64(n * n)
+		}
+		// Prefer the smallest rotation among equal scores
+		if score > bestScore || (score == bestScore && math.Abs(angle) < math.Abs(best)) {
+			best, bestScore = angle, score
+		}
+	}
+	return best
+}
+
+// rotate turns img by angle degrees around its center, filling the uncovered
+// corners with background.
+func rotate(img *image.RGBA, angle float64) *image.RGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	cx, cy := float64(w)/2, float64(h)/2
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	dest := image.NewRGBA(bounds)
+	for y := range h {
+		for x := range w {
+			// Map each destination pixel back to its source
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := int(math.Round(dx*cos + dy*sin + cx))
+			sy := int(math.Round(-dx*sin + dy*cos + cy))
+			ink := sx >= 0 && sx < w && sy >= 0 && sy < h && isInk(img.Pix, sy*img.Stride+sx*4)
+			setInk(dest.Pix, y*dest.Stride+x*4, ink)
+		}
+	}
+	return dest
+}
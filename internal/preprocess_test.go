@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"image"
+	"testing"
+)
+
+// binaryImage builds a binarized image from rows of '#' (ink) and '.'.
+func binaryImage(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			setInk(img.Pix, y*img.Stride+x*4, c == '#')
+		}
+	}
+	return img
+}
+
+func binaryRows(img *image.RGBA) []string {
+	bounds := img.Bounds()
+	rows := make([]string, bounds.Dy())
+	for y := range bounds.Dy() {
+		row := make([]byte, bounds.Dx())
+		for x := range bounds.Dx() {
+			row[x] = '.'
+			if isInk(img.Pix, y*img.Stride+x*4) {
+				row[x] = '#'
+			}
+		}
+		rows[y] = string(row)
+	}
+	return rows
+}
+
+func TestParsePipeline(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		expected []string
+		wantErr  bool
+	}{
+		{"", []string{}, false},
+		{"upscale", []string{"upscale"}, false},
+		{"upscale:2, denoise,deskew", []string{"upscale:2", "denoise", "deskew"}, false},
+		{"erode,dilate", []string{"erode", "dilate"}, false},
+		{"upscale:0", nil, true},
+		{"upscale:big", nil, true},
+		{"denoise:2", nil, true},
+		{"sharpen", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			steps, err := ParsePipeline(tc.spec)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(steps) != len(tc.expected) {
+				t.Fatalf("expected %v, but got %v", tc.expected, steps)
+			}
+			for i, step := range steps {
+				if step.String() != tc.expected[i] {
+					t.Errorf("expected step %d to be %s, got %s", i, tc.expected[i], step)
+				}
+			}
+		})
+	}
+}
+
+func TestMorphology(t *testing.T) {
+	testCases := []struct {
+		name     string
+		step     func(*image.RGBA) *image.RGBA
+		input    []string
+		expected []string
+	}{
+		{
+			name:     "denoise drops specks and fills pinholes",
+			step:     denoise,
+			input:    []string{"#.....", "...###", "...#.#", "...###"},
+			expected: []string{"......", "....#.", "...###", "....#."},
+		},
+		{
+			name:     "dilate",
+			step:     dilate,
+			input:    []string{".....", ".....", "..#..", ".....", "....."},
+			expected: []string{".....", ".###.", ".###.", ".###.", "....."},
+		},
+		{
+			name:     "erode",
+			step:     erode,
+			input:    []string{".....", ".###.", ".###.", ".###.", "....."},
+			expected: []string{".....", ".....", "..#..", ".....", "....."},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := binaryRows(tc.step(binaryImage(tc.input...)))
+			for y := range tc.expected {
+				if actual[y] != tc.expected[y] {
+					t.Fatalf("expected\n%v\nbut got\n%v", tc.expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestUpscale(t *testing.T) {
+	img := binaryImage("#..", "...")
+	actual := upscale(img, 3)
+	if actual.Bounds() != image.Rect(0, 0, 9, 6) {
+		t.Fatalf("expected 9x6 image, got %v", actual.Bounds())
+	}
+	if !isInk(actual.Pix, actual.Stride+4) {
+		t.Error("expected ink to be kept")
+	}
+	if isInk(actual.Pix, 4*actual.Stride+7*4) {
+		t.Error("expected background to stay background")
+	}
+	for i := 0; i < len(actual.Pix); i += 4 {
+		if v := actual.Pix[i]; v != 0 && v != 255 {
+			t.Fatalf("expected a binary image, got value %d", v)
+		}
+	}
+}
+
+func TestDeskew(t *testing.T) {
+	// A line of text leaning by about 3 degrees
+	img := image.NewRGBA(image.Rect(0, 0, 120, 30))
+	for i := 0; i < len(img.Pix); i += 4 {
+		setInk(img.Pix, i, false)
+	}
+	for x := 10; x < 110; x++ {
+		y := 15 + (x-60)*5/100
+		for dy := range 3 {
+			setInk(img.Pix, (y+dy)*img.Stride+x*4, true)
+		}
+	}
+
+	angle := skewAngle(img)
+	if angle == 0 {
+		t.Fatal("expected skew to be detected")
+	}
+	if angle := skewAngle(deskew(img)); angle != 0 {
+		t.Errorf("expected deskewed text to be straight, got %g degrees", angle)
+	}
+
+	straight := binaryImage("......", ".####.", "......")
+	if angle := skewAngle(straight); angle != 0 {
+		t.Errorf("expected straight text to be left alone, got %g degrees", angle)
+	}
+}
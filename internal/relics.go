@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+const (
+	// relicDropsURL serves the rewards of every relic, parsed from the
+	// official drop tables.
+	relicDropsURL = "https://drops.warframestat.us/data/relics.json"
+	// relicDropsMaxAge is how long the cached drop table is used before
+	// downloading it again, it only changes with game updates.
+	relicDropsMaxAge = 24 * time.Hour
+	// relicState is the refinement whose chances relics are valued with.
+	relicState = "Intact"
+)
+
+// relicGrid is the relic picker of the fissure lobby, the count owned is in
+// the top right corner of each tile and the theme is sampled from the title
+// above the tiles. TestScanGridCaptures reads the screen at
+// testdata/relics-1.png, with the relics expected in testdata/relics-1.txt.
+var relicGrid = itemGrid{
+	origin:  image.Pt(140, 235),
+	spacing: image.Pt(180, 200),
+	cols:    6,
+	rows:    3,
+	name:    image.Rect(5, 130, 175, 190),
+	count:   image.Rect(125, 5, 175, 35),
+	theme:   themeSample{text: image.Rect(144, 170, 148, 200)},
+}
+
+// relicDrops is the file format of the drop table.
+type relicDrops struct {
+	Relics []struct {
+		Tier    string        `json:"tier"`
+		Name    string        `json:"relicName"`
+		State   string        `json:"state"`
+		Rewards []relicReward `json:"rewards"`
+	} `json:"relics"`
+}
+
+// relicReward is an item a relic can reward and its chance in percent.
+type relicReward struct {
+	Item   string  `json:"itemName"`
+	Rarity string  `json:"rarity"`
+	Chance float64 `json:"chance"`
+}
+
+// getRelics returns the relics in the item catalog, with their names in lang
+// next to English.
+func getRelics(lang wfm.Language) []wfm.Item {
+	client := wfm.NewClient(wfm.WithLanguage(lang))
+	items, err := client.FetchItems()
+	if err != nil {
+		return nil
+	}
+	relics := []wfm.Item{}
+	for _, item := range items {
+		if slices.Contains(item.Tags, "relic") {
+			relics = append(relics, item)
+		}
+	}
+	return relics
+}
+
+// loadRelicDrops returns the rewards of every intact relic by its English
+// name, such as "Lith A1 Relic". The drop table is downloaded from url
+// unless the copy at cachePath is recent, which is also used when the
+// download fails.
+func loadRelicDrops(client *http.Client, url, cachePath string, now time.Time) (map[string][]relicReward, error) {
+	data, err := os.ReadFile(cachePath)
+	stale := err != nil
+	if info, statErr := os.Stat(cachePath); statErr == nil && now.Sub(info.ModTime()) > relicDropsMaxAge {
+		stale = true
+	}
+	if stale {
+		if fresh, err := downloadRelicDrops(client, url, cachePath); err == nil {
+			data = fresh
+		} else if data == nil {
+			return nil, fmt.Errorf("unable to download relic drop table: %w", err)
+		} else {
+			log.Printf("Using cached relic drop table, unable to download it: %v", err)
+		}
+	}
+
+	var drops relicDrops
+	if err := json.Unmarshal(data, &drops); err != nil {
+		return nil, fmt.Errorf("invalid relic drop table: %w", err)
+	}
+	rewards := map[string][]relicReward{}
+	for _, relic := range drops.Relics {
+		if relic.State == relicState {
+			rewards[relic.Tier+" "+relic.Name+" Relic"] = relic.Rewards
+		}
+	}
+	return rewards, nil
+}
+
+// relicDropTable loads the drop table of relicDropsURL, cached in the user
+// cache directory.
+func relicDropTable() (map[string][]relicReward, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return loadRelicDrops(&http.Client{Timeout: wfm.DefaultTimeout}, relicDropsURL, filepath.Join(cacheDir, "wfinfo-go", "relics.json"), time.Now())
+}
+
+func downloadRelicDrops(client *http.Client, url, cachePath string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+		_ = os.WriteFile(cachePath, data, 0o644)
+	}
+	return data, nil
+}
+
+// relicValue is the platinum an owned relic is expected to return.
+type relicValue struct {
+	OwnedItem
+	// Expected is the average price of its rewards weighted by their chance.
+	Expected float64
+	// Best is the most valuable reward and BestPrice its price.
+	Best      wfm.Item
+	BestPrice float64
+}
+
+// valueRelics values every relic of owned by the chance and price of its
+// rewards, most valuable first. items are the catalog the rewards of drops
+// are looked up in by English name, price returns the price of one.
+// Rewards without a price, such as Forma, count as worthless.
+func valueRelics(owned []OwnedItem, drops map[string][]relicReward, items []wfm.Item, price func(wfm.Item) (float64, error)) []relicValue {
+	byName := make(map[string]wfm.Item, len(items))
+	for _, item := range items {
+		byName[localizedName(item, wfm.LangEN)] = item
+	}
+	prices := map[string]float64{}
+	values := make([]relicValue, 0, len(owned))
+	for _, relic := range owned {
+		value := relicValue{OwnedItem: relic}
+		rewards, ok := drops[localizedName(relic.Item, wfm.LangEN)]
+		if !ok {
+			log.Printf("No drop table for %s", localizedName(relic.Item, wfm.LangEN))
+		}
+		for _, reward := range rewards {
+			item, ok := byName[reward.Item]
+			if !ok {
+				// The drop table leaves the Blueprint off warframe parts
+				item, ok = byName[reward.Item+" Blueprint"]
+			}
+			if !ok || slices.Contains(item.Tags, "forma") {
+				// Forma and rewards missing from the catalog aren't traded
+				continue
+			}
+			p, seen := prices[item.Id]
+			if !seen {
+				var err error
+				if p, err = price(item); err != nil {
+					log.Printf("Error: Unable to fetch price information for %v, %v\n", item.Id, err)
+				}
+				prices[item.Id] = p
+			}
+			value.Expected += reward.Chance / 100 * p
+			if p > value.BestPrice {
+				value.Best, value.BestPrice = item, p
+			}
+		}
+		values = append(values, value)
+	}
+	slices.SortStableFunc(values, func(a, b relicValue) int {
+		return cmp.Compare(b.Expected, a.Expected)
+	})
+	return values
+}
+
+// RunRelics captures the relic picker of the fissure lobby once, reads the
+// relics owned and prints them by the platinum they are expected to return.
+func RunRelics(cfg Config) error {
+	lang := gameLanguage(cfg.GameLanguage)
+	relics := getRelics(lang)
+	if len(relics) == 0 {
+		return fmt.Errorf("relic catalog unavailable")
+	}
+	owned, err := captureGrid(cfg, relicGrid, "relics", relics)
+	if err != nil {
+		return err
+	}
+	if len(owned) == 0 {
+		return fmt.Errorf("no relics found, is the relic picker open?")
+	}
+
+	drops, err := relicDropTable()
+	if err != nil {
+		return err
+	}
+
+	wfmClient := wfm.NewClient()
+	price := func(item wfm.Item) (float64, error) {
+		return itemPrice(wfmClient, item)
+	}
+	values := valueRelics(owned, drops, getRelicItems(lang), price)
+	printRelicValues(os.Stdout, values, displayNames(lang, cfg.DisplayLanguage))
+	return nil
+}
+
+// printRelicValues prints values, best first, naming items by names.
+func printRelicValues(w io.Writer, values []relicValue, names func(wfm.Item) string) {
+	for _, value := range values {
+		// Ex. Axi A1 Relic x3 - 12.34p expected, best Akstiletto Prime Barrel 40.00p
+		line := fmt.Sprintf("%v x%s - %.2fp expected", names(value.Item), value.countText(), value.Expected)
+		if value.BestPrice > 0 {
+			line += fmt.Sprintf(", best %v %.2fp", names(value.Best), value.BestPrice)
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+const testRelicDrops = `{"relics": [
+	{"tier": "Axi", "relicName": "V8", "state": "Intact", "rewards": [
+		{"itemName": "Forma Blueprint", "rarity": "Common", "chance": 25.33},
+		{"itemName": "Volt Prime Neuroptics", "rarity": "Uncommon", "chance": 11},
+		{"itemName": "Akstiletto Prime Barrel", "rarity": "Rare", "chance": 2}
+	]},
+	{"tier": "Axi", "relicName": "V8", "state": "Radiant", "rewards": [
+		{"itemName": "Akstiletto Prime Barrel", "rarity": "Rare", "chance": 10}
+	]}
+]}`
+
+func TestLoadRelicDrops(t *testing.T) {
+	var requests atomic.Int32
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(testRelicDrops))
+	}))
+	defer server.Close()
+	cachePath := filepath.Join(t.TempDir(), "wfinfo-go", "relics.json")
+	now := time.Now()
+
+	drops, err := loadRelicDrops(server.Client(), server.URL, cachePath, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rewards := drops["Axi V8 Relic"]
+	if len(drops) != 1 || len(rewards) != 3 || rewards[2].Chance != 2 {
+		t.Errorf("expected the 3 intact rewards of Axi V8, got %v", drops)
+	}
+
+	// A recent copy is used as is
+	if _, err := loadRelicDrops(server.Client(), server.URL, cachePath, now.Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected the drop table to be downloaded once, got %d requests", n)
+	}
+
+	// An old copy is downloaded again, and still used when that fails
+	fail.Store(true)
+	drops, err = loadRelicDrops(server.Client(), server.URL, cachePath, now.Add(2*relicDropsMaxAge))
+	if err != nil || len(drops["Axi V8 Relic"]) != 3 {
+		t.Errorf("expected the cached drop table, got %v, %v", drops, err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("expected an old drop table to be downloaded again, got %d requests", n)
+	}
+
+	if _, err := loadRelicDrops(server.Client(), server.URL, filepath.Join(t.TempDir(), "relics.json"), now); err == nil {
+		t.Error("expected an error without a drop table")
+	}
+	invalid := filepath.Join(t.TempDir(), "relics.json")
+	if err := os.WriteFile(invalid, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRelicDrops(server.Client(), server.URL, invalid, now); err == nil {
+		t.Error("expected an error for an invalid drop table")
+	}
+}
+
+func TestValueRelics(t *testing.T) {
+	item := func(id, name string, tags ...string) wfm.Item {
+		return wfm.Item{Id: id, Tags: tags, I18N: map[string]*wfm.ItemI18N{"en": {Name: name}}}
+	}
+	items := []wfm.Item{
+		item("forma", "Forma Blueprint", "forma"),
+		item("volt_prime_neuroptics", "Volt Prime Neuroptics Blueprint", "prime"),
+		item("akstiletto_prime_barrel", "Akstiletto Prime Barrel", "prime"),
+	}
+	drops := map[string][]relicReward{
+		"Axi V8 Relic": {
+			{Item: "Forma Blueprint", Chance: 25.33},
+			{Item: "Volt Prime Neuroptics", Chance: 11},
+			{Item: "Akstiletto Prime Barrel", Chance: 2},
+		},
+		"Lith A1 Relic": {{Item: "Akstiletto Prime Barrel", Chance: 25.33}},
+	}
+	owned := []OwnedItem{
+		{Item: item("lith_a1_relic", "Lith A1 Relic", "relic"), Count: 1},
+		{Item: item("axi_v8_relic", "Axi V8 Relic", "relic"), Count: 3},
+		{Item: item("neo_x1_relic", "Neo X1 Relic", "relic"), Count: 2},
+	}
+	prices := map[string]float64{"volt_prime_neuroptics": 100, "akstiletto_prime_barrel": 10}
+	fetched := map[string]int{}
+	price := func(item wfm.Item) (float64, error) {
+		fetched[item.Id]++
+		if p, ok := prices[item.Id]; ok {
+			return p, nil
+		}
+		return 0, fmt.Errorf("no orders")
+	}
+
+	values := valueRelics(owned, drops, items, price)
+	expected := []struct {
+		name     string
+		expected float64
+		best     string
+	}{
+		{"Axi V8 Relic", 11 + 0.2, "volt_prime_neuroptics"},
+		{"Lith A1 Relic", 2.533, "akstiletto_prime_barrel"},
+		{"Neo X1 Relic", 0, ""},
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("expected %d values, got %v", len(expected), values)
+	}
+	for i, e := range expected {
+		v := values[i]
+		if name := localizedName(v.Item, wfm.LangEN); name != e.name || math.Abs(v.Expected-e.expected) > 1e-9 || v.Best.Id != e.best {
+			t.Errorf("value %d: expected %s at %.3fp, best %q, got %s at %.3fp, best %q", i, e.name, e.expected, e.best, name, v.Expected, v.Best.Id)
+		}
+	}
+	if fetched["forma"] != 0 {
+		t.Error("expected Forma not to be priced")
+	}
+	if fetched["akstiletto_prime_barrel"] != 1 {
+		t.Errorf("expected every item to be priced once, got %v", fetched)
+	}
+}
+
+func TestPrintRelicValues(t *testing.T) {
+	relic := wfm.Item{I18N: map[string]*wfm.ItemI18N{"en": {Name: "Axi V8 Relic"}}}
+	best := wfm.Item{I18N: map[string]*wfm.ItemI18N{"en": {Name: "Volt Prime Neuroptics Blueprint"}}}
+	values := []relicValue{
+		{OwnedItem: OwnedItem{Item: relic, Count: 3}, Expected: 11.2, Best: best, BestPrice: 100},
+		{OwnedItem: OwnedItem{Item: relic, Count: 1}},
+	}
+	var out bytes.Buffer
+	printRelicValues(&out, values, func(item wfm.Item) string { return localizedName(item, wfm.LangEN) })
+	expected := "Axi V8 Relic x3 - 11.20p expected, best Volt Prime Neuroptics Blueprint 100.00p\n" +
+		"Axi V8 Relic x1 - 0.00p expected\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// ReplayConfig holds the options for Replay.
+type ReplayConfig struct {
+	LogPath string
+	// ScreenshotDir holds the images used in place of screen captures, in
+	// name order.
+	ScreenshotDir string
+	// Speed scales the delay between log lines, 0 replays without any delay.
+	Speed float64
+	// OCREngine is the name of the engine reading reward boxes, defaults to
+	// DefaultOCREngine.
+	OCREngine string
+	// GameLanguage is the language of the game client, reward text is read
+	// and matched in it. Defaults to English.
+	GameLanguage wfm.Language
+	// DisplayLanguage is the language results are printed in, defaults to
+	// GameLanguage.
+	DisplayLanguage wfm.Language
+	// OCRWorkers is the number of lines read in parallel, 0 picks a default.
+	OCRWorkers int
+	// Detect configures every detection.
+	Detect []DetectOption
+	// OwnedPartsPath is the file of the parts owned, defaults to a file in
+	// the user data directory.
+	OwnedPartsPath string
+}
+
+// Replay runs a recorded EE.log through the same pipeline as Run, using
+// screenshots instead of capturing the screen. Unlike Run it doesn't add the
+// reward screens to the history.
+func Replay(cfg ReplayConfig) error {
+	file, err := os.Open(cfg.LogPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Error closing file: %v", err)
+		}
+	}()
+
+	frames, err := newFileCapturer(cfg.ScreenshotDir)
+	if err != nil {
+		return err
+	}
+
+	ocr, err := NewOCREngine(cfg.OCREngine, cfg.OCRWorkers, cfg.GameLanguage)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := ocr.Close(); err != nil {
+			log.Printf("Error closing OCR engine: %v", err)
+		}
+	}()
+
+	clock := &replayClock{start: time.Now()}
+	s := &appState{
+		logParser: &logParser{
+			reader: bufio.NewReader(file),
+		},
+		detection:  &detectionState{},
+		foundItems: make(chan []wfm.Item),
+		ocr:        ocr,
+		capturer:   frames,
+		detectOpts: append([]DetectOption{WithLanguage(gameLanguage(cfg.GameLanguage))}, cfg.Detect...),
+		now:        clock.now,
+	}
+
+	ownedPath, err := ownedPartsPath(cfg.OwnedPartsPath)
+	if err != nil {
+		return err
+	}
+	wfmClient := wfm.NewClient()
+	sets := newSetTracker(wfm.NewClient(wfm.WithLanguage(gameLanguage(cfg.GameLanguage))), ownedPath)
+	names := displayNames(gameLanguage(cfg.GameLanguage), cfg.DisplayLanguage)
+
+	log.Printf("Replaying %s with screenshots from %s\n", cfg.LogPath, cfg.ScreenshotDir)
+
+	// Nothing may fail past this point, the goroutine is only waited for
+	// by the loop below
+	done := make(chan error, 1)
+	go func() {
+		done <- s.replayLog(clock, cfg.Speed)
+	}()
+
+	for {
+		select {
+		case items := <-s.foundItems:
+			prices := printItemPrices(wfmClient, items, names)
+			sets.printSets(os.Stdout, items, prices, names)
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+// replayLog feeds every line of the log to handleLine, sleeping between lines
+// according to their timestamps, and waits for pending detections to finish.
+func (s *appState) replayLog(clock *replayClock, speed float64) error {
+	defer s.pending.Wait()
+
+	for {
+		line, err := s.logParser.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line != "" {
+			if ts, ok := parseLogTimestamp(line); ok {
+				if delay := clock.advance(ts); speed > 0 && delay > 0 {
+					time.Sleep(time.Duration(float64(delay) / speed))
+				}
+			}
+			// A trailing line without a newline is complete in a recording.
+			s.handleLine(line, nil)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// parseLogTimestamp extracts the leading seconds-since-start timestamp of an
+// EE.log line, e.g. "123.456 Sys [Info]: ...".
+func parseLogTimestamp(line string) (time.Duration, bool) {
+	field, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	seconds, err := strconv.ParseFloat(field, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// replayClock maps log timestamps onto wall clock time, so rate limiting
+// behaves the same regardless of replay speed.
+type replayClock struct {
+	start   time.Time
+	first   time.Duration
+	current time.Duration
+	started bool
+}
+
+// advance moves the clock to ts and returns how much log time has passed.
+func (c *replayClock) advance(ts time.Duration) time.Duration {
+	if !c.started {
+		c.first, c.current, c.started = ts, ts, true
+		return 0
+	}
+	if ts < c.current {
+		return 0
+	}
+	delay := ts - c.current
+	c.current = ts
+	return delay
+}
+
+func (c *replayClock) now() time.Time {
+	return c.start.Add(c.current - c.first)
+}
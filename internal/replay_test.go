@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLogTimestamp(t *testing.T) {
+	testCases := []struct {
+		name     string
+		line     string
+		expected time.Duration
+		ok       bool
+	}{
+		{"timestamped line", "123.456 Sys [Info]: VoidProjections: GetVoidProjectionRewards\n", 123456 * time.Millisecond, true},
+		{"leading whitespace", "   5.5 Script [Info]: something", 5500 * time.Millisecond, true},
+		{"no timestamp", "Sys [Info]: something", 0, false},
+		{"empty line", "", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := parseLogTimestamp(tc.line)
+			if ok != tc.ok {
+				t.Fatalf("expected ok %v, but got %v", tc.ok, ok)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReplayClock(t *testing.T) {
+	start := time.Now()
+	clock := &replayClock{start: start}
+
+	if delay := clock.advance(10 * time.Second); delay != 0 {
+		t.Errorf("expected no delay for first timestamp, got %v", delay)
+	}
+	if delay := clock.advance(12 * time.Second); delay != 2*time.Second {
+		t.Errorf("expected 2s delay, got %v", delay)
+	}
+	// Timestamps going backwards should not move the clock
+	if delay := clock.advance(11 * time.Second); delay != 0 {
+		t.Errorf("expected no delay for earlier timestamp, got %v", delay)
+	}
+	if got := clock.now().Sub(start); got != 2*time.Second {
+		t.Errorf("expected clock at start+2s, got start+%v", got)
+	}
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+// setPart is a part of a set, how many the set takes and how many are owned.
+type setPart struct {
+	Item   wfm.Item
+	Needed int
+	Owned  int
+}
+
+// setSummary is the set a reward belongs to, the other parts it takes and
+// the price of the set against the sum of its parts.
+type setSummary struct {
+	Set    wfm.Item
+	Others []setPart
+	// SetPrice is 0 when the set has no price, and PartsPrice adds up the
+	// parts with one, those without are in Unpriced.
+	SetPrice   float64
+	PartsPrice float64
+	Unpriced   []wfm.Item
+}
+
+// summarizeSet summarizes set for a reward of item, the parts other than
+// the reward being a copy of it less. It returns false when set has no root
+// item or item is its only part. price returns the price of one part or set,
+// those it fails to price are left out of the prices and their errors
+// returned with the summary.
+func summarizeSet(set *wfm.ItemSet, item wfm.Item, owned ownedParts, price func(wfm.Item) (float64, error)) (setSummary, bool, error) {
+	rootIndex := slices.IndexFunc(set.Items, func(i wfm.Item) bool {
+		return i.SetRoot != nil && *i.SetRoot
+	})
+	if rootIndex < 0 || len(set.Items) < 3 {
+		return setSummary{}, false, nil
+	}
+	summary := setSummary{Set: set.Items[rootIndex]}
+	var errs []error
+	setPrice, err := price(summary.Set)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", summary.Set.Id, err))
+	} else {
+		summary.SetPrice = setPrice
+	}
+	for i, part := range set.Items {
+		if i == rootIndex {
+			continue
+		}
+		needed := max(int(part.QuantityInSet), 1)
+		partPrice, err := price(part)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", part.Id, err))
+			summary.Unpriced = append(summary.Unpriced, part)
+		} else {
+			summary.PartsPrice += partPrice * float64(needed)
+		}
+		if part.Id == item.Id {
+			// The reward is one of the copies needed
+			needed--
+		}
+		if needed > 0 {
+			summary.Others = append(summary.Others, setPart{Item: part, Needed: needed, Owned: owned[part.Id]})
+		}
+	}
+	return summary, true, errors.Join(errs...)
+}
+
+// printSetSummary prints the line of summary under its reward, naming items
+// by names.
+func printSetSummary(w io.Writer, summary setSummary, names func(wfm.Item) string) error {
+	var have, needed int
+	missing := []string{}
+	for _, part := range summary.Others {
+		have += min(part.Owned, part.Needed)
+		needed += part.Needed
+		if part.Owned < part.Needed {
+			missing = append(missing, names(part.Item))
+		}
+	}
+	setPrice := "n/a"
+	if summary.SetPrice > 0 {
+		setPrice = fmt.Sprintf("%.2fp", summary.SetPrice)
+	}
+	// Ex.   Tekko Prime Set - 1/2 other parts owned, 25.00p as a set, 20.00p in parts, missing Tekko Prime Blueprint
+	line := fmt.Sprintf("  %s - %d/%d other parts owned, %s as a set, %.2fp in parts", names(summary.Set), have, needed, setPrice, summary.PartsPrice)
+	if len(summary.Unpriced) > 0 {
+		unpriced := make([]string, 0, len(summary.Unpriced))
+		for _, part := range summary.Unpriced {
+			unpriced = append(unpriced, names(part))
+		}
+		line += " (" + strings.Join(unpriced, ", ") + " n/a)"
+	}
+	if len(missing) > 0 {
+		line += ", missing " + strings.Join(missing, ", ")
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// setTracker looks up the sets of rewards and the parts owned of them.
+type setTracker struct {
+	client     *wfm.Client
+	ownedPath  string
+	sets       map[string]*wfm.ItemSet
+	noSetItems map[string]bool
+	// price returns the price of one part or set, defaults to itemPrice.
+	price func(wfm.Item) (float64, error)
+}
+
+func newSetTracker(client *wfm.Client, ownedPath string) *setTracker {
+	return &setTracker{
+		client:     client,
+		ownedPath:  ownedPath,
+		sets:       map[string]*wfm.ItemSet{},
+		noSetItems: map[string]bool{},
+		price: func(item wfm.Item) (float64, error) {
+			return itemPrice(client, item)
+		},
+	}
+}
+
+// printSets prints the sets of the rewards items to w, once their prices
+// are printed. Every price is looked up once per screen: prices, those of
+// items with 0 for none, are reused and so are parts shared by rewards. Sets
+// are fetched once and the owned parts file is read every screen, as the
+// inventory command may have updated it since.
+func (t *setTracker) printSets(w io.Writer, items []wfm.Item, prices []float64, names func(wfm.Item) string) {
+	cache := map[string]float64{}
+	for i, item := range items {
+		if prices[i] > 0 {
+			cache[item.Id] = prices[i]
+		}
+	}
+	failed := map[string]error{}
+	price := func(item wfm.Item) (float64, error) {
+		if p, ok := cache[item.Id]; ok {
+			return p, nil
+		}
+		if err, ok := failed[item.Id]; ok {
+			return 0, err
+		}
+		p, err := t.price(item)
+		if err != nil {
+			failed[item.Id] = err
+			return 0, err
+		}
+		cache[item.Id] = p
+		return p, nil
+	}
+	owned, err := loadOwnedParts(t.ownedPath)
+	if err != nil {
+		log.Printf("Error: Unable to read owned parts, %v\n", err)
+		owned = ownedParts{}
+	}
+
+	printed := map[string]bool{}
+	for _, item := range items {
+		if printed[item.Id] {
+			continue
+		}
+		printed[item.Id] = true
+		summary, ok := t.summarize(item, owned, price)
+		if !ok {
+			continue
+		}
+		if err := printSetSummary(w, summary, names); err != nil {
+			log.Printf("Error printing set: %v", err)
+		}
+	}
+}
+
+// summarize returns the summary of the set of item, false when it has none
+// or it couldn't be looked up. Parts that couldn't be priced are logged and
+// left in the summary as unpriced.
+func (t *setTracker) summarize(item wfm.Item, owned ownedParts, price func(wfm.Item) (float64, error)) (setSummary, bool) {
+	if t.noSetItems[item.Id] || slices.Contains(item.Tags, "forma") {
+		return setSummary{}, false
+	}
+	set, ok := t.sets[item.Id]
+	if !ok {
+		var err error
+		set, err = t.client.FetchItemSet(item.Slug)
+		if err != nil {
+			log.Printf("Error: Unable to fetch the set of %v, %v\n", item.Id, err)
+			return setSummary{}, false
+		}
+		t.sets[item.Id] = set
+	}
+	summary, ok, err := summarizeSet(set, item, owned, price)
+	if err != nil {
+		log.Printf("Error: Unable to price all of the set of %v, %v\n", item.Id, err)
+	}
+	if !ok {
+		t.noSetItems[item.Id] = true
+	}
+	return summary, ok
+}
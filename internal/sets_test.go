@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/simon-wg/wfinfo-go/internal/wfm"
+)
+
+func setItem(id, name string, quantity int32, root bool) wfm.Item {
+	item := inventoryItem(id, name, 0)
+	item.QuantityInSet = quantity
+	item.SetRoot = &root
+	return item
+}
+
+func TestSummarizeSet(t *testing.T) {
+	set := &wfm.ItemSet{Items: []wfm.Item{
+		setItem("lex_prime_set", "Lex Prime Set", 0, true),
+		setItem("lex_prime_barrel", "Lex Prime Barrel", 1, false),
+		setItem("lex_prime_receiver", "Lex Prime Receiver", 1, false),
+		setItem("lex_prime_blueprint", "Lex Prime Blueprint", 1, false),
+		setItem("fang_prime_blade", "Fang Prime Blade", 2, false),
+	}}
+	prices := map[string]float64{"lex_prime_set": 40, "lex_prime_barrel": 5, "lex_prime_receiver": 10, "lex_prime_blueprint": 3, "fang_prime_blade": 4}
+	price := func(item wfm.Item) (float64, error) {
+		if p, ok := prices[item.Id]; ok {
+			return p, nil
+		}
+		return 0, fmt.Errorf("no sell orders")
+	}
+	owned := ownedParts{"lex_prime_receiver": 2, "fang_prime_blade": 1}
+
+	summary, ok, err := summarizeSet(set, set.Items[1], owned, price)
+	if err != nil || !ok {
+		t.Fatalf("expected a set, got %v, %v", ok, err)
+	}
+	if summary.Set.Id != "lex_prime_set" || summary.SetPrice != 40 || summary.PartsPrice != 26 {
+		t.Errorf("expected Lex Prime Set at 40.00p against 26.00p in parts, got %s at %.2fp against %.2fp", summary.Set.Id, summary.SetPrice, summary.PartsPrice)
+	}
+	expected := []setPart{{set.Items[2], 1, 2}, {set.Items[3], 1, 0}, {set.Items[4], 2, 1}}
+	if len(summary.Others) != len(expected) {
+		t.Fatalf("expected %d other parts, got %v", len(expected), summary.Others)
+	}
+	for i, e := range expected {
+		if part := summary.Others[i]; part.Item.Id != e.Item.Id || part.Needed != e.Needed || part.Owned != e.Owned {
+			t.Errorf("part %d: expected %s %d/%d, got %s %d/%d", i, e.Item.Id, e.Owned, e.Needed, part.Item.Id, part.Owned, part.Needed)
+		}
+	}
+
+	if _, ok, err := summarizeSet(&wfm.ItemSet{Items: set.Items[1:]}, set.Items[1], owned, price); ok || err != nil {
+		t.Errorf("expected no set without a root, got %v, %v", ok, err)
+	}
+	if _, ok, err := summarizeSet(&wfm.ItemSet{Items: set.Items[:2]}, set.Items[1], owned, price); ok || err != nil {
+		t.Errorf("expected no set of a single part, got %v, %v", ok, err)
+	}
+
+	// A reward the set takes two of still leaves one to get
+	summary, ok, err = summarizeSet(set, set.Items[4], owned, price)
+	if err != nil || !ok {
+		t.Fatalf("expected a set, got %v, %v", ok, err)
+	}
+	if len(summary.Others) != 4 || summary.Others[3].Item.Id != "fang_prime_blade" || summary.Others[3].Needed != 1 {
+		t.Errorf("expected one other Fang Prime Blade, got %v", summary.Others)
+	}
+
+	// An unpriced part is left out of the parts' price
+	delete(prices, "lex_prime_blueprint")
+	summary, ok, err = summarizeSet(set, set.Items[1], owned, price)
+	if err == nil || !ok {
+		t.Fatalf("expected a set and an error for an unpriced part, got %v, %v", ok, err)
+	}
+	if summary.PartsPrice != 23 || len(summary.Unpriced) != 1 || summary.Unpriced[0].Id != "lex_prime_blueprint" {
+		t.Errorf("expected 23.00p in parts without Lex Prime Blueprint, got %.2fp without %v", summary.PartsPrice, summary.Unpriced)
+	}
+	if len(summary.Others) != 3 {
+		t.Errorf("expected the unpriced part among the others, got %v", summary.Others)
+	}
+}
+
+func TestPrintSetSummary(t *testing.T) {
+	summary := setSummary{
+		Set: inventoryItem("lex_prime_set", "Lex Prime Set", 0),
+		Others: []setPart{
+			{inventoryItem("lex_prime_receiver", "Lex Prime Receiver", 45), 1, 2},
+			{inventoryItem("lex_prime_blueprint", "Lex Prime Blueprint", 15), 1, 0},
+			{inventoryItem("fang_prime_blade", "Fang Prime Blade", 45), 2, 1},
+		},
+		SetPrice:   40,
+		PartsPrice: 26,
+	}
+	var out bytes.Buffer
+	if err := printSetSummary(&out, summary, func(item wfm.Item) string { return localizedName(item, wfm.LangEN) }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "  Lex Prime Set - 2/4 other parts owned, 40.00p as a set, 26.00p in parts, missing Lex Prime Blueprint, Fang Prime Blade\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+
+	summary.SetPrice = 0
+	summary.PartsPrice = 23
+	summary.Unpriced = []wfm.Item{summary.Others[1].Item}
+	out.Reset()
+	if err := printSetSummary(&out, summary, func(item wfm.Item) string { return localizedName(item, wfm.LangEN) }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = "  Lex Prime Set - 2/4 other parts owned, n/a as a set, 23.00p in parts (Lex Prime Blueprint n/a), missing Lex Prime Blueprint, Fang Prime Blade\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestPrintSets(t *testing.T) {
+	set := &wfm.ItemSet{Items: []wfm.Item{
+		setItem("lex_prime_set", "Lex Prime Set", 0, true),
+		setItem("lex_prime_barrel", "Lex Prime Barrel", 1, false),
+		setItem("lex_prime_receiver", "Lex Prime Receiver", 1, false),
+		setItem("lex_prime_blueprint", "Lex Prime Blueprint", 1, false),
+	}}
+	forma := formaItem()
+	tracker := newSetTracker(nil, filepath.Join(t.TempDir(), "owned-parts.json"))
+	tracker.sets = map[string]*wfm.ItemSet{"lex_prime_barrel": set, "lex_prime_receiver": set}
+	lookups := map[string]int{}
+	prices := map[string]float64{"lex_prime_set": 40, "lex_prime_barrel": 5, "lex_prime_receiver": 10, "lex_prime_blueprint": 3}
+	tracker.price = func(item wfm.Item) (float64, error) {
+		lookups[item.Id]++
+		return prices[item.Id], nil
+	}
+
+	items := []wfm.Item{set.Items[1], set.Items[2], forma, set.Items[1]}
+	var out bytes.Buffer
+	tracker.printSets(&out, items, []float64{5, 10, 0, 5}, func(item wfm.Item) string { return localizedName(item, wfm.LangEN) })
+	expected := "" +
+		"  Lex Prime Set - 0/2 other parts owned, 40.00p as a set, 18.00p in parts, missing Lex Prime Receiver, Lex Prime Blueprint\n" +
+		"  Lex Prime Set - 0/2 other parts owned, 40.00p as a set, 18.00p in parts, missing Lex Prime Barrel, Lex Prime Blueprint\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+	// The rewards' prices are reused and the rest looked up once
+	if len(lookups) != 2 || lookups["lex_prime_set"] != 1 || lookups["lex_prime_blueprint"] != 1 {
+		t.Errorf("expected the set and blueprint looked up once each, got %v", lookups)
+	}
+}
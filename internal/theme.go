@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// defaultThemeThreshold is the color distance isolateTargetColor accepts as
+// text for custom and unknown themes.
+const defaultThemeThreshold = 60
+
+// maxThemeDistance is how far a sampled text color may be from a known theme
+// and still be identified as it.
+const maxThemeDistance = 40
+
+// Theme is a UI theme's reward text color and the distance from it that still
+// counts as text. AccentColor is the color of the screen title, which tells
+// apart themes sharing a text color; it's unset when unknown.
+type Theme struct {
+	Name        string
+	TextColor   color.RGBA
+	AccentColor color.RGBA
+	Threshold   float64
+}
+
+func (t Theme) String() string {
+	return fmt.Sprintf("%s (%s, threshold %g)", t.Name, formatHexColor(t.TextColor), t.Threshold)
+}
+
+// themes are the known UI themes, measured from their reward screens in
+// testdata. The threshold of each is the middle of the range of distances at
+// which every reward name of its screenshot splits into the right letters.
+// Vitruvian has no screenshot yet, its text color is the one the game uses
+// and it keeps the default threshold.
+var themes = []Theme{
+	{Name: "Conquera", TextColor: color.RGBA{R: 255, G: 255, B: 255, A: 255}, AccentColor: color.RGBA{R: 245, G: 227, B: 173, A: 255}, Threshold: 70},
+	{Name: "Contrast", TextColor: color.RGBA{R: 102, G: 176, B: 255, A: 255}, AccentColor: color.RGBA{R: 255, G: 255, B: 0, A: 255}, Threshold: 75},
+	{Name: "Equinox", TextColor: color.RGBA{R: 158, G: 159, B: 167, A: 255}, AccentColor: color.RGBA{R: 232, G: 227, B: 227, A: 255}, Threshold: 65},
+	{Name: "Harrier", TextColor: color.RGBA{R: 253, G: 132, B: 2, A: 255}, AccentColor: color.RGBA{R: 255, G: 53, B: 0, A: 255}, Threshold: 100},
+	{Name: "Legacy", TextColor: color.RGBA{R: 255, G: 255, B: 255, A: 255}, AccentColor: color.RGBA{R: 232, G: 213, B: 93, A: 255}, Threshold: 105},
+	{Name: "Renewal", TextColor: color.RGBA{R: 255, G: 255, B: 255, A: 255}, AccentColor: color.RGBA{R: 207, G: 176, B: 82, A: 255}, Threshold: 120},
+	{Name: "Vitruvian", TextColor: color.RGBA{R: 190, G: 169, B: 102, A: 255}, Threshold: defaultThemeThreshold},
+}
+
+// Themes lists the names of the known UI themes.
+func Themes() []string {
+	names := make([]string, 0, len(themes))
+	for _, theme := range themes {
+		names = append(names, theme.Name)
+	}
+	return names
+}
+
+// LookupTheme returns the known theme called name, ignoring case.
+func LookupTheme(name string) (Theme, error) {
+	for _, theme := range themes {
+		if strings.EqualFold(theme.Name, name) {
+			return theme, nil
+		}
+	}
+	return Theme{}, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(Themes(), ", "))
+}
+
+// CustomTheme returns a theme for a user-defined text color such as
+// "#bea966". A threshold of 0 uses the default.
+func CustomTheme(hex string, threshold float64) (Theme, error) {
+	c, err := parseHexColor(hex)
+	if err != nil {
+		return Theme{}, err
+	}
+	if threshold < 0 {
+		return Theme{}, fmt.Errorf("invalid theme threshold %g", threshold)
+	}
+	if threshold == 0 {
+		threshold = defaultThemeThreshold
+	}
+	return Theme{Name: "Custom", TextColor: c, Threshold: threshold}, nil
+}
+
+// identifyTheme returns the known theme closest to the sampled text and
+// accent colors, a theme being as far as the farthest of its colors. An
+// accent left unset isn't compared, themes sharing a text color then go to
+// the first of them. Unknown themes get the sampled text color with the
+// default threshold.
+func identifyTheme(text, accent color.RGBA) Theme {
+	best, bestDistance := Theme{}, math.Inf(1)
+	for _, theme := range themes {
+		d := colorDistance(text, theme.TextColor)
+		if theme.AccentColor.A != 0 && accent.A != 0 {
+			d = max(d, colorDistance(accent, theme.AccentColor))
+		}
+		if d < bestDistance {
+			best, bestDistance = theme, d
+		}
+	}
+	if bestDistance > maxThemeDistance {
+		return Theme{Name: "Unknown", TextColor: text, Threshold: defaultThemeThreshold}
+	}
+	return best
+}
+
+func colorDistance(a, b color.RGBA) float64 {
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+func parseHexColor(hex string) (color.RGBA, error) {
+	var c color.RGBA
+	s := strings.TrimPrefix(hex, "#")
+	if len(s) != 6 {
+		return c, fmt.Errorf("invalid color %q, expected #RRGGBB", hex)
+	}
+	if _, err := fmt.Sscanf(s, "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		return c, fmt.Errorf("invalid color %q, expected #RRGGBB", hex)
+	}
+	c.A = 255
+	return c, nil
+}
+
+func formatHexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestIdentifyTheme(t *testing.T) {
+	testCases := []struct {
+		imagePath string
+		expected  string
+	}{
+		{"testdata/conquera-1.png", "Conquera"},
+		{"testdata/contrast-1.png", "Contrast"},
+		{"testdata/equinox-1.png", "Equinox"},
+		{"testdata/harrier-1.png", "Harrier"},
+		{"testdata/legacy-1.png", "Legacy"},
+		{"testdata/renewal-1.png", "Renewal"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			img := loadTestImage(t, tc.imagePath)
+			if theme := detectTheme(img, rewardThemeSample); theme.Name != tc.expected {
+				t.Errorf("expected %s, but got %v", tc.expected, theme)
+			}
+		})
+	}
+}
+
+func TestThemeTextSample(t *testing.T) {
+	testCases := []struct {
+		name          string
+		imagePath     string
+		expectedColor color.RGBA
+	}{
+		{"conquera", "testdata/conquera-1.png", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"contrast", "testdata/contrast-1.png", color.RGBA{R: 102, G: 176, B: 255, A: 255}},
+		{"equinox", "testdata/equinox-1.png", color.RGBA{R: 158, G: 159, B: 167, A: 255}},
+		{"harrier", "testdata/harrier-1.png", color.RGBA{R: 253, G: 132, B: 2, A: 255}},
+		{"legacy", "testdata/legacy-1.png", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"renewal", "testdata/renewal-1.png", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"vitruvian", "testdata/vitruvian-1.png", color.RGBA{R: 190, G: 169, B: 102, A: 255}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := loadTestImage(t, tc.imagePath)
+			actualColor := averageColor(img, rewardThemeSample.text)
+			if actualColor != tc.expectedColor {
+				t.Errorf("expected color %v, but got %v", tc.expectedColor, actualColor)
+			}
+		})
+	}
+}
+
+func TestIdentifyThemeNearby(t *testing.T) {
+	// A slightly off sample still maps to the theme's exact color
+	theme := identifyTheme(color.RGBA{R: 248, G: 140, B: 10, A: 255}, color.RGBA{R: 250, G: 60, B: 8, A: 255})
+	if theme.Name != "Harrier" {
+		t.Errorf("expected Harrier, got %v", theme)
+	}
+
+	// White text is told apart by the accent
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	for accent, expected := range map[color.RGBA]string{
+		{R: 240, G: 225, B: 170, A: 255}: "Conquera",
+		{R: 228, G: 208, B: 100, A: 255}: "Legacy",
+		{R: 210, G: 180, B: 80, A: 255}:  "Renewal",
+	} {
+		if theme := identifyTheme(white, accent); theme.Name != expected {
+			t.Errorf("expected %s for accent %v, got %v", expected, accent, theme)
+		}
+	}
+
+	// Without a measured accent the text color alone counts
+	theme = identifyTheme(color.RGBA{R: 185, G: 165, B: 105, A: 255}, color.RGBA{A: 255})
+	if theme.Name != "Vitruvian" {
+		t.Errorf("expected Vitruvian, got %v", theme)
+	}
+
+	sample := color.RGBA{R: 20, G: 200, B: 40, A: 255}
+	theme = identifyTheme(sample, sample)
+	if theme.Name != "Unknown" || theme.TextColor != sample || theme.Threshold != defaultThemeThreshold {
+		t.Errorf("expected unknown theme using the sample, got %v", theme)
+	}
+}
+
+func TestLookupTheme(t *testing.T) {
+	theme, err := LookupTheme("equinox")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if theme.Name != "Equinox" {
+		t.Errorf("expected Equinox, got %v", theme)
+	}
+	if _, err := LookupTheme("lotus"); err == nil {
+		t.Error("expected error for unknown theme")
+	}
+}
+
+func TestCustomTheme(t *testing.T) {
+	testCases := []struct {
+		hex       string
+		threshold float64
+		expected  Theme
+		wantErr   bool
+	}{
+		{"#bea966", 0, Theme{Name: "Custom", TextColor: color.RGBA{R: 190, G: 169, B: 102, A: 255}, Threshold: defaultThemeThreshold}, false},
+		{"24B8F2", 45, Theme{Name: "Custom", TextColor: color.RGBA{R: 36, G: 184, B: 242, A: 255}, Threshold: 45}, false},
+		{"#fff", 0, Theme{}, true},
+		{"#gggggg", 0, Theme{}, true},
+		{"#ffffff", -1, Theme{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.hex, func(t *testing.T) {
+			actual, err := CustomTheme(tc.hex, tc.threshold)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package wfm
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Item struct {
 	Id             string               `json:"id"`
 	Slug           string               `json:"slug"`
@@ -437,6 +442,23 @@ const (
 	LangEN     Language = "en"
 )
 
+// Languages lists the languages the API has translations for.
+var Languages = []Language{LangEN, LangDE, LangES, LangFR, LangIT, LangKO, LangPL, LangPT, LangRU, LangUK, LangZHSimp, LangZHTrad}
+
+// ParseLanguage returns the language with the code s, such as "de".
+func ParseLanguage(s string) (Language, error) {
+	for _, lang := range Languages {
+		if strings.EqualFold(string(lang), s) {
+			return lang, nil
+		}
+	}
+	codes := make([]string, 0, len(Languages))
+	for _, lang := range Languages {
+		codes = append(codes, string(lang))
+	}
+	return "", fmt.Errorf("unknown language %q (available: %s)", s, strings.Join(codes, ", "))
+}
+
 type Platform string
 
 const (
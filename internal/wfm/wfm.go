@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -22,6 +23,7 @@ type Client struct {
 	httpClient *http.Client
 	baseURL    *url.URL
 	ctx        context.Context
+	language   Language
 }
 
 // ClientOption is a function that configures a Client.
@@ -41,6 +43,14 @@ func WithHTTPClient(client *http.Client) ClientOption {
 	}
 }
 
+// WithLanguage requests translations in lang next to English, for the
+// I18N of items and other resources.
+func WithLanguage(lang Language) ClientOption {
+	return func(c *Client) {
+		c.language = lang
+	}
+}
+
 // NewClient creates a new Warframe Market API client.
 func NewClient(opts ...ClientOption) *Client {
 	u, _ := url.Parse(DefaultBaseURL)
@@ -84,6 +94,10 @@ func fetchResource[T any](c *Client, query url.Values, path ...string) (T, error
 		return zero, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	if c.language != "" {
+		req.Header.Set("Language", string(c.language))
+	}
+
 	var resp genericResponse[T]
 	if err := c.do(req, &resp); err != nil {
 		var zero T
@@ -140,15 +154,15 @@ func (c *Client) do(req *http.Request, v any) error {
 func (c *Client) FetchItems() ([]Item, error) {
 	currentVersions, err := c.FetchVersions()
 	if err != nil {
-		if items, err := getFromCache[[]Item](c, "items.json"); err == nil {
+		if items, err := getFromCache[[]Item](c, c.languageCacheName("items.json")); err == nil {
 			return *items, nil
 		}
 		return nil, fmt.Errorf("failed to fetch versions: %w", err)
 	}
 
-	cachedVersions, _ := getFromCache[Versions](c, "versions.json")
+	cachedVersions, _ := getFromCache[Versions](c, c.languageCacheName("versions.json"))
 	if cachedVersions != nil && *currentVersions == *cachedVersions {
-		if items, err := getFromCache[[]Item](c, "items.json"); err == nil {
+		if items, err := getFromCache[[]Item](c, c.languageCacheName("items.json")); err == nil {
 			return *items, nil
 		}
 	}
@@ -158,12 +172,22 @@ func (c *Client) FetchItems() ([]Item, error) {
 		return nil, err
 	}
 
-	saveToCache(c, "items.json", items)
-	saveToCache(c, "versions.json", currentVersions)
+	saveToCache(c, c.languageCacheName("items.json"), items)
+	saveToCache(c, c.languageCacheName("versions.json"), currentVersions)
 
 	return items, nil
 }
 
+// languageCacheName keeps the cached translations of each language apart,
+// English keeps the original file names.
+func (c *Client) languageCacheName(filename string) string {
+	if c.language == "" || c.language == LangEN {
+		return filename
+	}
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-" + string(c.language) + ext
+}
+
 func (c *Client) getCachePath(filename string) string {
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
@@ -123,3 +123,41 @@ func TestGenericResponseDecoding(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, resp.Data)
 	}
 }
+
+func TestWithLanguage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lang := r.Header.Get("Language"); lang != "de" {
+			t.Errorf("Expected Language header de, got %q", lang)
+		}
+		_ = json.NewEncoder(w).Encode(genericResponse[*Item]{
+			Data: &Item{Slug: "ash-prime"},
+		})
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	client := NewClient(WithBaseURL(u), WithLanguage(LangDE))
+	if _, err := client.FetchItem("ash-prime"); err != nil {
+		t.Fatalf("FetchItem failed: %v", err)
+	}
+
+	if name := client.languageCacheName("items.json"); name != "items-de.json" {
+		t.Errorf("Expected items-de.json, got %s", name)
+	}
+	if name := NewClient().languageCacheName("items.json"); name != "items.json" {
+		t.Errorf("Expected items.json, got %s", name)
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	lang, err := ParseLanguage("ZH-HANS")
+	if err != nil {
+		t.Fatalf("ParseLanguage failed: %v", err)
+	}
+	if lang != LangZHSimp {
+		t.Errorf("Expected %s, got %s", LangZHSimp, lang)
+	}
+	if _, err := ParseLanguage("xx"); err == nil {
+		t.Error("Expected error for unknown language")
+	}
+}
@@ -1,88 +1,483 @@
 package internal
 
 import (
+	"errors"
+	"fmt"
 	"image"
 	"log"
 	"strings"
+	"sync"
 
 	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/randr"
 	"github.com/jezek/xgb/xproto"
 )
 
-func screenshot() image.Image {
-	X, err := xgb.NewConn()
+func init() {
+	registerCapturer("x11", func(cfg CaptureConfig) (Capturer, error) {
+		return &x11Session{match: cfg.Window, output: cfg.Output}, nil
+	})
+}
+
+// x11Session captures the Warframe window over a long-lived X connection. The
+// window is looked up once and cached until the server reports it destroyed or
+// unmapped. Captures go through MIT-SHM when the server supports it.
+//
+// Under gamescope and some compositors the window contents read back black.
+// The session then captures the game's area of the root window instead, which
+// is also used from the start when a RandR output is configured.
+type x11Session struct {
+	mu     sync.Mutex
+	match  WindowMatch
+	output string
+	conn   *xgb.Conn
+	root   xproto.Window
+	atoms  *windowAtoms
+	window xproto.Window
+	shm    *shmSegment
+	// useRoot is set once window captures turned out black.
+	useRoot bool
+	// area is the game's area of the root window, empty until located and
+	// again after Relocate.
+	area image.Rectangle
+}
+
+func (s *x11Session) Capture() (image.Image, error) {
+	return s.CaptureRegion(image.Rectangle{})
+}
+
+// CaptureRegion captures rect of the Warframe window, in window coordinates.
+// An empty rect captures the whole window.
+func (s *x11Session) CaptureRegion(rect image.Rectangle) (image.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, err := s.capture(rect)
+	if err != nil {
+		// Start from a fresh connection next time, the server or window may
+		// have gone away.
+		s.close()
+		return nil, err
+	}
+	return img, nil
+}
+
+// Relocate forgets the game's area of the root window, locating it again on
+// the next capture in case the bars around it were misread.
+func (s *x11Session) Relocate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.area = image.Rectangle{}
+}
+
+// Close releases the shared memory segment and the X connection.
+func (s *x11Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.close()
+	return nil
+}
+
+func (s *x11Session) capture(rect image.Rectangle) (*image.RGBA, error) {
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	s.processEvents()
+	if err := s.findWindow(); err != nil && s.output == "" {
+		return nil, err
+	}
+
+	if s.output == "" && !s.useRoot {
+		img, err := s.captureWindow(rect)
+		if err != nil || !isBlackFrame(img) {
+			return img, err
+		}
+		log.Println("Warframe window captures are black, capturing its area of the screen instead")
+		s.useRoot = true
+	}
+	return s.captureRoot(rect)
+}
+
+func (s *x11Session) captureWindow(rect image.Rectangle) (*image.RGBA, error) {
+	geom, err := xproto.GetGeometry(s.conn, xproto.Drawable(s.window)).Reply()
 	if err != nil {
-		log.Fatal("unable to find xserver")
+		return nil, fmt.Errorf("unable to get window geometry: %w", err)
+	}
+	bounds := image.Rect(0, 0, int(geom.Width), int(geom.Height))
+	if rect.Empty() {
+		rect = bounds
 	}
-	defer X.Close()
-	setup := xproto.Setup(X)
-	root := setup.DefaultScreen(X).Root
-	targetWin := findWindow(X, root, "steam_app_230410")
-	if targetWin == 0 {
-		log.Fatalf("unable to find Warframe window")
+	rect = rect.Intersect(bounds)
+	if rect.Empty() {
+		return nil, fmt.Errorf("capture region is outside the %dx%d window", bounds.Dx(), bounds.Dy())
 	}
-	log.Printf("found warframe window id %d\n", targetWin)
-	geom, err := xproto.GetGeometry(X, xproto.Drawable(targetWin)).Reply()
+	return s.captureDrawable(xproto.Drawable(s.window), rect)
+}
+
+// captureRoot captures rect, in game coordinates, of the game's area of the
+// root window.
+func (s *x11Session) captureRoot(rect image.Rectangle) (*image.RGBA, error) {
+	area, err := s.gameArea()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, area.Dx(), area.Dy())
+	if rect.Empty() {
+		rect = bounds
+	}
+	rect = rect.Intersect(bounds)
+	if rect.Empty() {
+		return nil, fmt.Errorf("capture region is outside the %dx%d game area", bounds.Dx(), bounds.Dy())
 	}
-	reply, err := xproto.GetImage(X, xproto.ImageFormatZPixmap, xproto.Drawable(targetWin), 0, 0, geom.Width, geom.Height, 0xffffffff).Reply()
+	img, err := s.captureDrawable(xproto.Drawable(s.root), rect.Add(area.Min))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	img := x11ToImage(reply.Data, int(geom.Width), int(geom.Height))
-	return img
+	img.Rect = rect
+	return img, nil
 }
 
-func findWindow(X *xgb.Conn, parent xproto.Window, targetClass string) xproto.Window {
-	tree, err := xproto.QueryTree(X, parent).Reply()
+// gameArea locates the game on the root window: the configured output, the
+// window's position or the whole screen, without any black bars around it.
+func (s *x11Session) gameArea() (image.Rectangle, error) {
+	if !s.area.Empty() {
+		return s.area, nil
+	}
+
+	var area image.Rectangle
+	switch {
+	case s.output != "":
+		var err error
+		if area, err = outputArea(s.conn, s.root, s.output); err != nil {
+			return area, err
+		}
+	case s.window != 0:
+		geom, err := xproto.GetGeometry(s.conn, xproto.Drawable(s.window)).Reply()
+		if err != nil {
+			return area, fmt.Errorf("unable to get window geometry: %w", err)
+		}
+		pos, err := xproto.TranslateCoordinates(s.conn, s.window, s.root, 0, 0).Reply()
+		if err != nil {
+			return area, fmt.Errorf("unable to locate window on screen: %w", err)
+		}
+		area = image.Rect(int(pos.DstX), int(pos.DstY), int(pos.DstX)+int(geom.Width), int(pos.DstY)+int(geom.Height))
+	default:
+		screen := xproto.Setup(s.conn).DefaultScreen(s.conn)
+		area = image.Rect(0, 0, int(screen.WidthInPixels), int(screen.HeightInPixels))
+	}
+
+	rootGeom, err := xproto.GetGeometry(s.conn, xproto.Drawable(s.root)).Reply()
 	if err != nil {
-		return 0
+		return area, fmt.Errorf("unable to get screen geometry: %w", err)
+	}
+	area = area.Intersect(image.Rect(0, 0, int(rootGeom.Width), int(rootGeom.Height)))
+	if area.Empty() {
+		return area, errors.New("game area is off screen")
 	}
 
-	for _, child := range tree.Children {
-		match := false
-		prop, err := xproto.GetProperty(X, false, child, xproto.AtomWmClass, xproto.AtomString, 0, 1024).Reply()
-		if err == nil && len(prop.Value) > 0 {
-			if strings.Contains(strings.ToLower(string(prop.Value)), strings.ToLower(targetClass)) {
-				match = true
+	// Scaled or letterboxed games leave black bars around the picture
+	img, err := s.captureDrawable(xproto.Drawable(s.root), area)
+	if err != nil {
+		return area, err
+	}
+	content := contentBounds(img)
+	if content.Empty() {
+		return area, errors.New("screen capture is black")
+	}
+	log.Printf("located game at %dx%d+%d+%d on screen\n", content.Dx(), content.Dy(), content.Min.X, content.Min.Y)
+	s.area = content
+	return content, nil
+}
+
+// outputArea returns the position of the RandR output called name.
+func outputArea(X *xgb.Conn, root xproto.Window, name string) (image.Rectangle, error) {
+	if err := randr.Init(X); err != nil {
+		return image.Rectangle{}, fmt.Errorf("RandR unavailable: %w", err)
+	}
+	resources, err := randr.GetScreenResourcesCurrent(X, root).Reply()
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("unable to list outputs: %w", err)
+	}
+	names := []string{}
+	for _, output := range resources.Outputs {
+		info, err := randr.GetOutputInfo(X, output, resources.ConfigTimestamp).Reply()
+		if err != nil {
+			continue
+		}
+		if string(info.Name) != name {
+			if info.Crtc != 0 {
+				names = append(names, string(info.Name))
 			}
+			continue
 		}
+		if info.Crtc == 0 {
+			return image.Rectangle{}, fmt.Errorf("output %s is not active", name)
+		}
+		crtc, err := randr.GetCrtcInfo(X, info.Crtc, resources.ConfigTimestamp).Reply()
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("unable to get output %s position: %w", name, err)
+		}
+		return image.Rect(int(crtc.X), int(crtc.Y), int(crtc.X)+int(crtc.Width), int(crtc.Y)+int(crtc.Height)), nil
+	}
+	return image.Rectangle{}, fmt.Errorf("unknown output %s (active: %s)", name, strings.Join(names, ", "))
+}
+
+// captureDrawable captures rect of drawable, keeping rect as the bounds of
+// the returned image.
+func (s *x11Session) captureDrawable(drawable xproto.Drawable, rect image.Rectangle) (*image.RGBA, error) {
+	data, depth, visual, err := s.getImage(drawable, rect)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get window image: %w", err)
+	}
+	format, err := pixelFormatFor(xproto.Setup(s.conn), depth, visual)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode window image: %w", err)
+	}
+	img, err := x11ToImage(data, rect.Dx(), rect.Dy(), format)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode window image: %w", err)
+	}
+	img.Rect = img.Rect.Add(rect.Min)
+	return img, nil
+}
+
+func (s *x11Session) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return fmt.Errorf("unable to connect to X server: %w", err)
+	}
+	s.conn = conn
+	s.root = xproto.Setup(conn).DefaultScreen(conn).Root
+	atoms, err := internWindowAtoms(conn)
+	if err != nil {
+		conn.Close()
+		s.conn = nil
+		return fmt.Errorf("unable to query X atoms: %w", err)
+	}
+	s.atoms = atoms
 
-		if match {
-			attr, err := xproto.GetWindowAttributes(X, child).Reply()
-			isViewable := (err == nil && attr.MapState == xproto.MapStateViewable)
+	shm, err := newShmSegment(conn)
+	if err != nil {
+		log.Printf("MIT-SHM unavailable, using regular X11 capture: %v", err)
+	}
+	s.shm = shm
+	return nil
+}
 
-			geom, err := xproto.GetGeometry(X, xproto.Drawable(child)).Reply()
-			isLargeEnough := (err == nil && geom.Width > 10 && geom.Height > 10)
+func (s *x11Session) close() {
+	if s.conn == nil {
+		return
+	}
+	if s.shm != nil {
+		s.shm.close(s.conn)
+		s.shm = nil
+	}
+	s.conn.Close()
+	s.conn = nil
+	s.window = 0
+	s.useRoot = false
+	s.area = image.Rectangle{}
+}
 
-			if isViewable && isLargeEnough {
-				return child
+// processEvents drains queued events and forgets the cached window once it is
+// destroyed or unmapped, and its located area once it changes.
+func (s *x11Session) processEvents() {
+	for {
+		ev, err := s.conn.PollForEvent()
+		if ev == nil && err == nil {
+			return
+		}
+		switch ev := ev.(type) {
+		case xproto.DestroyNotifyEvent:
+			if ev.Window == s.window {
+				s.window = 0
+				s.area = image.Rectangle{}
+			}
+		case xproto.UnmapNotifyEvent:
+			if ev.Window == s.window {
+				s.window = 0
+				s.area = image.Rectangle{}
+			}
+		case xproto.ConfigureNotifyEvent:
+			if ev.Window == s.window {
+				s.area = image.Rectangle{}
 			}
 		}
-		if found := findWindow(X, child, targetClass); found != 0 {
-			return found
+	}
+}
+
+func (s *x11Session) findWindow() error {
+	if s.window != 0 {
+		return nil
+	}
+	window, err := findWindow(s.conn, s.atoms, s.root, s.match)
+	if err != nil {
+		return err
+	}
+	// Ask for structure events so we hear about the window going away
+	if err := xproto.ChangeWindowAttributesChecked(s.conn, window, xproto.CwEventMask, []uint32{xproto.EventMaskStructureNotify}).Check(); err != nil {
+		return fmt.Errorf("unable to watch Warframe window: %w", err)
+	}
+	log.Printf("found warframe window id %d\n", window)
+	s.window = window
+	return nil
+}
+
+// getImage returns the ZPixmap data of rect along with its depth and visual.
+func (s *x11Session) getImage(drawable xproto.Drawable, rect image.Rectangle) ([]byte, byte, xproto.Visualid, error) {
+	x, y := int16(rect.Min.X), int16(rect.Min.Y)
+	width, height := uint16(rect.Dx()), uint16(rect.Dy())
+
+	if s.shm != nil {
+		data, depth, visual, err := s.shm.getImage(s.conn, drawable, x, y, width, height)
+		if err == nil {
+			return data, depth, visual, nil
 		}
+		log.Printf("MIT-SHM capture failed, using regular X11 capture: %v", err)
+		s.shm.close(s.conn)
+		s.shm = nil
 	}
 
-	return 0
+	reply, err := xproto.GetImage(s.conn, xproto.ImageFormatZPixmap, drawable, x, y, width, height, 0xffffffff).Reply()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return reply.Data, reply.Depth, reply.Visual, nil
+}
+
+// pixelFormat describes how the server lays out ZPixmap image data for a
+// particular depth and visual.
+type pixelFormat struct {
+	bitsPerPixel int
+	scanlinePad  int
+	msbFirst     bool
+	redMask      uint32
+	greenMask    uint32
+	blueMask     uint32
 }
 
-// x11ToImage converts the BGRA byte slice from X11 to an image.RGBA
-func x11ToImage(data []byte, width, height int) *image.RGBA {
+// pixelFormatFor looks up the layout of images with depth and visual in the
+// server's setup information.
+func pixelFormatFor(setup *xproto.SetupInfo, depth byte, visual xproto.Visualid) (pixelFormat, error) {
+	format := pixelFormat{msbFirst: setup.ImageByteOrder == xproto.ImageOrderMSBFirst}
+
+	found := false
+	for _, f := range setup.PixmapFormats {
+		if f.Depth == depth {
+			format.bitsPerPixel = int(f.BitsPerPixel)
+			format.scanlinePad = int(f.ScanlinePad)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return format, fmt.Errorf("no pixmap format for depth %d", depth)
+	}
+
+	for _, screen := range setup.Roots {
+		for _, d := range screen.AllowedDepths {
+			for _, v := range d.Visuals {
+				if v.VisualId != visual {
+					continue
+				}
+				if v.Class != xproto.VisualClassTrueColor && v.Class != xproto.VisualClassDirectColor {
+					return format, fmt.Errorf("unsupported visual class %d", v.Class)
+				}
+				format.redMask, format.greenMask, format.blueMask = v.RedMask, v.GreenMask, v.BlueMask
+				return format, nil
+			}
+		}
+	}
+	return format, fmt.Errorf("unknown visual %d", visual)
+}
+
+// x11ToImage converts ZPixmap data in the given format to an image.RGBA.
+func x11ToImage(data []byte, width, height int, format pixelFormat) (*image.RGBA, error) {
+	bpp := format.bitsPerPixel
+	if bpp != 16 && bpp != 24 && bpp != 32 {
+		return nil, fmt.Errorf("unsupported bits per pixel %d", bpp)
+	}
+	if format.scanlinePad < bpp {
+		return nil, fmt.Errorf("invalid scanline pad %d", format.scanlinePad)
+	}
+	bytesPerPixel := bpp / 8
+	pad := format.scanlinePad
+	stride := (width*bpp + pad - 1) / pad * pad / 8
+	if height > 0 && len(data) < stride*(height-1)+width*bytesPerPixel {
+		return nil, fmt.Errorf("image data too short: %d bytes for %dx%d", len(data), width, height)
+	}
+
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	// X11 usually sends BGRA or BGRA; standard Go image is RGBA.
-	for i := 0; i < len(data); i += 4 {
-		b := data[i]
-		g := data[i+1]
-		r := data[i+2]
-		a := data[i+3]
-
-		offset := i
-		img.Pix[offset] = r
-		img.Pix[offset+1] = g
-		img.Pix[offset+2] = b
-		img.Pix[offset+3] = a
-	}
-	return img
+
+	// Fast path for the usual 32-bit little-endian BGRX layout. The fourth
+	// byte is padding or alpha we don't trust, so pixels are made opaque.
+	if bpp == 32 && !format.msbFirst && format.redMask == 0xff0000 && format.greenMask == 0xff00 && format.blueMask == 0xff {
+		for y := range height {
+			src := data[y*stride:]
+			dst := img.Pix[y*img.Stride:]
+			for x := range width {
+				i, o := x*4, x*4
+				dst[o] = src[i+2]
+				dst[o+1] = src[i+1]
+				dst[o+2] = src[i]
+				dst[o+3] = 255
+			}
+		}
+		return img, nil
+	}
+
+	red := newChannel(format.redMask)
+	green := newChannel(format.greenMask)
+	blue := newChannel(format.blueMask)
+	for y := range height {
+		row := data[y*stride:]
+		dst := img.Pix[y*img.Stride:]
+		for x := range width {
+			var pixel uint32
+			for b := range bytesPerPixel {
+				if format.msbFirst {
+					pixel = pixel<<8 | uint32(row[x*bytesPerPixel+b])
+				} else {
+					pixel |= uint32(row[x*bytesPerPixel+b]) << (8 * b)
+				}
+			}
+			o := x * 4
+			dst[o] = red.value(pixel)
+			dst[o+1] = green.value(pixel)
+			dst[o+2] = blue.value(pixel)
+			dst[o+3] = 255
+		}
+	}
+	return img, nil
+}
+
+// channel extracts one color component of a pixel by its visual mask.
+type channel struct {
+	mask  uint32
+	shift int
+	max   uint32
+}
+
+func newChannel(mask uint32) channel {
+	c := channel{mask: mask}
+	if mask == 0 {
+		return c
+	}
+	for mask&1 == 0 {
+		mask >>= 1
+		c.shift++
+	}
+	c.max = mask
+	return c
+}
+
+// value scales the component to 8 bits.
+func (c channel) value(pixel uint32) uint8 {
+	if c.max == 0 {
+		return 0
+	}
+	return uint8(((pixel & c.mask) >> c.shift) * 255 / c.max)
 }
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/shm"
+	"github.com/jezek/xgb/xproto"
+	"golang.org/x/sys/unix"
+)
+
+// shmSegment is a System V shared memory segment attached to the X server,
+// letting GetImage write pixels straight into our memory instead of sending
+// them over the socket.
+type shmSegment struct {
+	seg  shm.Seg
+	data []byte
+}
+
+func newShmSegment(conn *xgb.Conn) (*shmSegment, error) {
+	if err := shm.Init(conn); err != nil {
+		return nil, err
+	}
+	if _, err := shm.QueryVersion(conn).Reply(); err != nil {
+		return nil, err
+	}
+	seg, err := shm.NewSegId(conn)
+	if err != nil {
+		return nil, err
+	}
+	return &shmSegment{seg: seg}, nil
+}
+
+func (s *shmSegment) getImage(conn *xgb.Conn, drawable xproto.Drawable, x, y int16, width, height uint16) ([]byte, byte, xproto.Visualid, error) {
+	// 4 bytes per pixel covers every depth up to 32 bits including padding
+	if err := s.reserve(conn, int(width)*int(height)*4); err != nil {
+		return nil, 0, 0, err
+	}
+	reply, err := shm.GetImage(conn, drawable, x, y, width, height, 0xffffffff, xproto.ImageFormatZPixmap, s.seg, 0).Reply()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if int(reply.Size) > len(s.data) {
+		return nil, 0, 0, fmt.Errorf("image of %d bytes does not fit shared memory of %d bytes", reply.Size, len(s.data))
+	}
+	return s.data[:reply.Size], reply.Depth, reply.Visual, nil
+}
+
+// reserve makes sure the attached segment holds at least size bytes,
+// replacing it with a larger one if needed.
+func (s *shmSegment) reserve(conn *xgb.Conn, size int) error {
+	if len(s.data) >= size {
+		return nil
+	}
+	s.release(conn)
+
+	id, err := unix.SysvShmGet(unix.IPC_PRIVATE, size, unix.IPC_CREAT|0600)
+	if err != nil {
+		return fmt.Errorf("unable to create shared memory: %w", err)
+	}
+	// The segment is freed once both we and the server have detached
+	defer func() { _, _ = unix.SysvShmCtl(id, unix.IPC_RMID, nil) }()
+
+	data, err := unix.SysvShmAttach(id, 0, 0)
+	if err != nil {
+		return fmt.Errorf("unable to attach shared memory: %w", err)
+	}
+	if err := shm.AttachChecked(conn, s.seg, uint32(id), false).Check(); err != nil {
+		_ = unix.SysvShmDetach(data)
+		return fmt.Errorf("X server unable to attach shared memory: %w", err)
+	}
+	s.data = data
+	return nil
+}
+
+func (s *shmSegment) release(conn *xgb.Conn) {
+	if s.data == nil {
+		return
+	}
+	shm.Detach(conn, s.seg)
+	_ = unix.SysvShmDetach(s.data)
+	s.data = nil
+}
+
+func (s *shmSegment) close(conn *xgb.Conn) {
+	s.release(conn)
+}
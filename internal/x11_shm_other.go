@@ -0,0 +1,23 @@
+//go:build !linux
+
+package internal
+
+import (
+	"errors"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// shmSegment is unsupported outside Linux, captures use regular GetImage.
+type shmSegment struct{}
+
+func newShmSegment(*xgb.Conn) (*shmSegment, error) {
+	return nil, errors.New("MIT-SHM capture is only supported on Linux")
+}
+
+func (s *shmSegment) getImage(*xgb.Conn, xproto.Drawable, int16, int16, uint16, uint16) ([]byte, byte, xproto.Visualid, error) {
+	return nil, 0, 0, errors.New("MIT-SHM capture is only supported on Linux")
+}
+
+func (s *shmSegment) close(*xgb.Conn) {}
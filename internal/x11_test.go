@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/jezek/xgb/xproto"
+)
+
+func TestX11ToImage(t *testing.T) {
+	// Two pixels per row, red then blue, two rows
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	testCases := []struct {
+		name   string
+		format pixelFormat
+		data   []byte
+	}{
+		{
+			name:   "32bpp BGRX little-endian",
+			format: pixelFormat{bitsPerPixel: 32, scanlinePad: 32, redMask: 0xff0000, greenMask: 0xff00, blueMask: 0xff},
+			data: []byte{
+				0, 0, 255, 0x12, 255, 0, 0, 0x34,
+				0, 0, 255, 0x56, 255, 0, 0, 0x78,
+			},
+		},
+		{
+			name:   "32bpp XRGB big-endian",
+			format: pixelFormat{bitsPerPixel: 32, scanlinePad: 32, msbFirst: true, redMask: 0xff0000, greenMask: 0xff00, blueMask: 0xff},
+			data: []byte{
+				0, 255, 0, 0, 0, 0, 0, 255,
+				0, 255, 0, 0, 0, 0, 0, 255,
+			},
+		},
+		{
+			name:   "32bpp RGBX little-endian",
+			format: pixelFormat{bitsPerPixel: 32, scanlinePad: 32, redMask: 0xff, greenMask: 0xff00, blueMask: 0xff0000},
+			data: []byte{
+				255, 0, 0, 0, 0, 0, 255, 0,
+				255, 0, 0, 0, 0, 0, 255, 0,
+			},
+		},
+		{
+			name:   "24bpp packed with padded scanlines",
+			format: pixelFormat{bitsPerPixel: 24, scanlinePad: 32, redMask: 0xff0000, greenMask: 0xff00, blueMask: 0xff},
+			data: []byte{
+				0, 0, 255, 255, 0, 0, 0xaa, 0xbb,
+				0, 0, 255, 255, 0, 0, 0xcc, 0xdd,
+			},
+		},
+		{
+			name:   "16bpp RGB565 little-endian",
+			format: pixelFormat{bitsPerPixel: 16, scanlinePad: 32, redMask: 0xf800, greenMask: 0x07e0, blueMask: 0x001f},
+			data: []byte{
+				0x00, 0xf8, 0x1f, 0x00,
+				0x00, 0xf8, 0x1f, 0x00,
+			},
+		},
+		{
+			name:   "16bpp RGB565 big-endian",
+			format: pixelFormat{bitsPerPixel: 16, scanlinePad: 32, msbFirst: true, redMask: 0xf800, greenMask: 0x07e0, blueMask: 0x001f},
+			data: []byte{
+				0xf8, 0x00, 0x00, 0x1f,
+				0xf8, 0x00, 0x00, 0x1f,
+			},
+		},
+		{
+			name:   "32bpp with 64-bit scanline pad",
+			format: pixelFormat{bitsPerPixel: 32, scanlinePad: 64, redMask: 0xff0000, greenMask: 0xff00, blueMask: 0xff},
+			data: []byte{
+				0, 0, 255, 0, 255, 0, 0, 0,
+				0, 0, 255, 0, 255, 0, 0, 0,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := x11ToImage(tc.data, 2, 2, tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for y := range 2 {
+				if got := img.RGBAAt(0, y); got != red {
+					t.Errorf("expected red at (0,%d), got %v", y, got)
+				}
+				if got := img.RGBAAt(1, y); got != blue {
+					t.Errorf("expected blue at (1,%d), got %v", y, got)
+				}
+			}
+		})
+	}
+}
+
+func TestX11ToImageErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format pixelFormat
+		data   []byte
+	}{
+		{"unsupported depth", pixelFormat{bitsPerPixel: 8, scanlinePad: 32}, make([]byte, 16)},
+		{"short data", pixelFormat{bitsPerPixel: 32, scanlinePad: 32}, make([]byte, 12)},
+		{"padded rows too short", pixelFormat{bitsPerPixel: 24, scanlinePad: 32}, make([]byte, 13)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := x11ToImage(tc.data, 2, 2, tc.format); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestPixelFormatFor(t *testing.T) {
+	setup := &xproto.SetupInfo{
+		ImageByteOrder: xproto.ImageOrderMSBFirst,
+		PixmapFormats: []xproto.Format{
+			{Depth: 24, BitsPerPixel: 32, ScanlinePad: 32},
+			{Depth: 16, BitsPerPixel: 16, ScanlinePad: 32},
+		},
+		Roots: []xproto.ScreenInfo{{
+			AllowedDepths: []xproto.DepthInfo{
+				{Depth: 24, Visuals: []xproto.VisualInfo{
+					{VisualId: 33, Class: xproto.VisualClassTrueColor, RedMask: 0xff0000, GreenMask: 0xff00, BlueMask: 0xff},
+				}},
+				{Depth: 8, Visuals: []xproto.VisualInfo{
+					{VisualId: 40, Class: xproto.VisualClassPseudoColor},
+				}},
+			},
+		}},
+	}
+
+	format, err := pixelFormatFor(setup, 24, 33)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := pixelFormat{bitsPerPixel: 32, scanlinePad: 32, msbFirst: true, redMask: 0xff0000, greenMask: 0xff00, blueMask: 0xff}
+	if format != want {
+		t.Errorf("expected %+v, got %+v", want, format)
+	}
+
+	if _, err := pixelFormatFor(setup, 30, 33); err == nil {
+		t.Error("expected error for depth without pixmap format")
+	}
+	if _, err := pixelFormatFor(setup, 16, 99); err == nil {
+		t.Error("expected error for unknown visual")
+	}
+	if _, err := pixelFormatFor(setup, 16, 40); err == nil {
+		t.Error("expected error for palette visual")
+	}
+}
@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// warframeWindowClass is the WM_CLASS Proton gives the game when launched
+// through Steam.
+const warframeWindowClass = "steam_app_230410"
+
+// WindowMatch selects the game window among the X11 windows. Every field that
+// is set must match, an empty WindowMatch matches the Steam window class.
+type WindowMatch struct {
+	// Class is a case-insensitive substring of WM_CLASS.
+	Class string
+	// Title is a case-insensitive substring of _NET_WM_NAME or WM_NAME.
+	Title string
+	// PID is the process id in _NET_WM_PID.
+	PID uint32
+	// ID is an explicit window id, skipping the search entirely.
+	ID uint32
+}
+
+func (m WindowMatch) isZero() bool {
+	return m == WindowMatch{}
+}
+
+func (m WindowMatch) String() string {
+	if m.isZero() {
+		m.Class = warframeWindowClass
+	}
+	parts := []string{}
+	if m.ID != 0 {
+		parts = append(parts, fmt.Sprintf("id 0x%x", m.ID))
+	}
+	if m.Class != "" {
+		parts = append(parts, fmt.Sprintf("class %q", m.Class))
+	}
+	if m.Title != "" {
+		parts = append(parts, fmt.Sprintf("title %q", m.Title))
+	}
+	if m.PID != 0 {
+		parts = append(parts, fmt.Sprintf("pid %d", m.PID))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// matches reports whether the window satisfies every set field. It does not
+// check whether the window is usable for capture.
+func (m WindowMatch) matches(w windowInfo) bool {
+	if m.isZero() {
+		m.Class = warframeWindowClass
+	}
+	if m.ID != 0 && uint32(w.id) != m.ID {
+		return false
+	}
+	if m.Class != "" && !strings.Contains(strings.ToLower(w.class), strings.ToLower(m.Class)) {
+		return false
+	}
+	if m.Title != "" && !strings.Contains(strings.ToLower(w.title), strings.ToLower(m.Title)) {
+		return false
+	}
+	if m.PID != 0 && w.pid != m.PID {
+		return false
+	}
+	return true
+}
+
+// windowInfo holds the properties used to pick the game window.
+type windowInfo struct {
+	id       xproto.Window
+	class    string
+	title    string
+	pid      uint32
+	width    uint16
+	height   uint16
+	viewable bool
+}
+
+// capturable reports whether the window is mapped and big enough to be the
+// game rather than a helper window.
+func (w windowInfo) capturable() bool {
+	return w.viewable && w.width > 10 && w.height > 10
+}
+
+// windowAtoms are the non-predefined atoms needed to read window properties.
+type windowAtoms struct {
+	netWmName  xproto.Atom
+	netWmPid   xproto.Atom
+	utf8String xproto.Atom
+}
+
+func internWindowAtoms(X *xgb.Conn) (*windowAtoms, error) {
+	names := []string{"_NET_WM_NAME", "_NET_WM_PID", "UTF8_STRING"}
+	cookies := make([]xproto.InternAtomCookie, len(names))
+	for i, name := range names {
+		cookies[i] = xproto.InternAtom(X, false, uint16(len(name)), name)
+	}
+	atoms := make([]xproto.Atom, len(names))
+	for i, cookie := range cookies {
+		reply, err := cookie.Reply()
+		if err != nil {
+			return nil, err
+		}
+		atoms[i] = reply.Atom
+	}
+	return &windowAtoms{netWmName: atoms[0], netWmPid: atoms[1], utf8String: atoms[2]}, nil
+}
+
+func getWindowInfo(X *xgb.Conn, atoms *windowAtoms, window xproto.Window) windowInfo {
+	info := windowInfo{id: window}
+
+	// Send every request before waiting so this costs a single round trip
+	classCookie := xproto.GetProperty(X, false, window, xproto.AtomWmClass, xproto.AtomString, 0, 1024)
+	netNameCookie := xproto.GetProperty(X, false, window, atoms.netWmName, atoms.utf8String, 0, 1024)
+	nameCookie := xproto.GetProperty(X, false, window, xproto.AtomWmName, xproto.AtomString, 0, 1024)
+	pidCookie := xproto.GetProperty(X, false, window, atoms.netWmPid, xproto.AtomCardinal, 0, 1)
+	attrCookie := xproto.GetWindowAttributes(X, window)
+	geomCookie := xproto.GetGeometry(X, xproto.Drawable(window))
+
+	if prop, err := classCookie.Reply(); err == nil {
+		// WM_CLASS is "instance\0class\0"
+		info.class = strings.Join(strings.FieldsFunc(string(prop.Value), func(r rune) bool { return r == 0 }), " ")
+	}
+	netName, netNameErr := netNameCookie.Reply()
+	name, nameErr := nameCookie.Reply()
+	if netNameErr == nil && len(netName.Value) > 0 {
+		info.title = string(netName.Value)
+	} else if nameErr == nil {
+		info.title = string(name.Value)
+	}
+	if prop, err := pidCookie.Reply(); err == nil && len(prop.Value) >= 4 {
+		info.pid = xgb.Get32(prop.Value)
+	}
+	attr, err := attrCookie.Reply()
+	info.viewable = err == nil && attr.MapState == xproto.MapStateViewable
+	if geom, err := geomCookie.Reply(); err == nil {
+		info.width, info.height = geom.Width, geom.Height
+	}
+	return info
+}
+
+// findWindow returns the first capturable window below root matching match.
+func findWindow(X *xgb.Conn, atoms *windowAtoms, root xproto.Window, match WindowMatch) (xproto.Window, error) {
+	if match.ID != 0 {
+		info := getWindowInfo(X, atoms, xproto.Window(match.ID))
+		if !info.capturable() {
+			return 0, fmt.Errorf("window 0x%x is not a visible window", match.ID)
+		}
+		return info.id, nil
+	}
+
+	found := xproto.Window(0)
+	err := walkWindows(X, root, func(window xproto.Window) bool {
+		info := getWindowInfo(X, atoms, window)
+		if match.matches(info) && info.capturable() {
+			found = window
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if found == 0 {
+		return 0, fmt.Errorf("unable to find a visible Warframe window matching %v, is the game running and not minimized?", match)
+	}
+	return found, nil
+}
+
+// walkWindows calls visit for every window below parent, depth first, until
+// visit returns false.
+func walkWindows(X *xgb.Conn, parent xproto.Window, visit func(xproto.Window) bool) error {
+	tree, err := xproto.QueryTree(X, parent).Reply()
+	if err != nil {
+		return fmt.Errorf("unable to query window tree: %w", err)
+	}
+	var walk func([]xproto.Window) bool
+	walk = func(windows []xproto.Window) bool {
+		for _, window := range windows {
+			if !visit(window) {
+				return false
+			}
+			// Windows can disappear while walking, skip their children
+			if tree, err := xproto.QueryTree(X, window).Reply(); err == nil && !walk(tree.Children) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(tree.Children)
+	return nil
+}
+
+// ListWindows prints the named X11 windows, marking those match selects, to
+// help configure window targeting.
+func ListWindows(w io.Writer, match WindowMatch) error {
+	X, err := xgb.NewConn()
+	if err != nil {
+		return fmt.Errorf("unable to connect to X server: %w", err)
+	}
+	defer X.Close()
+	atoms, err := internWindowAtoms(X)
+	if err != nil {
+		return fmt.Errorf("unable to query X atoms: %w", err)
+	}
+	root := xproto.Setup(X).DefaultScreen(X).Root
+
+	windows := []windowInfo{}
+	err = walkWindows(X, root, func(window xproto.Window) bool {
+		info := getWindowInfo(X, atoms, window)
+		if info.class != "" || info.title != "" {
+			windows = append(windows, info)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if len(windows) == 0 {
+		return errors.New("no named windows found")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "MATCH\tID\tPID\tSIZE\tVISIBLE\tCLASS\tTITLE")
+	for _, info := range windows {
+		mark := ""
+		if match.matches(info) && info.capturable() {
+			mark = "*"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t0x%x\t%d\t%dx%d\t%v\t%s\t%s\n", mark, uint32(info.id), info.pid, info.width, info.height, info.viewable, info.class, info.title)
+	}
+	return tw.Flush()
+}
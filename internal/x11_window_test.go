@@ -0,0 +1,72 @@
+package internal
+
+import "testing"
+
+func TestWindowMatch(t *testing.T) {
+	steam := windowInfo{id: 0x3a00007, class: "steam_app_230410 steam_app_230410", title: "Warframe", pid: 4242, width: 1920, height: 1080, viewable: true}
+	lutris := windowInfo{id: 0x4200003, class: "warframe.x64.exe Warframe.x64.exe", title: "Warframe", pid: 5151, width: 1920, height: 1080, viewable: true}
+
+	testCases := []struct {
+		name     string
+		match    WindowMatch
+		window   windowInfo
+		expected bool
+	}{
+		{"default matches steam class", WindowMatch{}, steam, true},
+		{"default misses other launchers", WindowMatch{}, lutris, false},
+		{"class is case-insensitive", WindowMatch{Class: "WARFRAME.X64"}, lutris, true},
+		{"title", WindowMatch{Title: "warframe"}, lutris, true},
+		{"title mismatch", WindowMatch{Title: "Steam"}, lutris, false},
+		{"pid", WindowMatch{PID: 5151}, lutris, true},
+		{"pid mismatch", WindowMatch{PID: 5151}, steam, false},
+		{"explicit id", WindowMatch{ID: 0x3a00007}, steam, true},
+		{"all fields must match", WindowMatch{Title: "Warframe", PID: 4242}, lutris, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.match.matches(tc.window); actual != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWindowCapturable(t *testing.T) {
+	testCases := []struct {
+		name     string
+		window   windowInfo
+		expected bool
+	}{
+		{"visible game window", windowInfo{width: 1920, height: 1080, viewable: true}, true},
+		{"minimized", windowInfo{width: 1920, height: 1080}, false},
+		{"helper window", windowInfo{width: 1, height: 1, viewable: true}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.window.capturable(); actual != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWindowMatchString(t *testing.T) {
+	testCases := []struct {
+		match    WindowMatch
+		expected string
+	}{
+		{WindowMatch{}, `class "steam_app_230410"`},
+		{WindowMatch{Title: "Warframe", PID: 12}, `title "Warframe", pid 12`},
+		{WindowMatch{ID: 0x3a00007}, "id 0x3a00007"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			if actual := tc.match.String(); actual != tc.expected {
+				t.Errorf("expected %q, but got %q", tc.expected, actual)
+			}
+		})
+	}
+}